@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/s0ultr4d3r/psstelebot/tiles"
+)
+
+// подкоманда: gpx2gif tiles cache stats|prune|clear [-tileCache dir] [-tileTTL d] [-tileCacheMax size]
+func tilesCmd(args []string) error {
+	const usage = "использование: tiles cache stats|prune|clear [-tileCache папка] [-tileTTL 720h] [-tileCacheMax 1G]"
+	if len(args) < 2 || args[0] != "cache" { return errors.New(usage) }
+	op := args[1]
+	// флаги те же, что у рендера: -tileCache, -tileTTL, -tileCacheMax
+	if err := flag.CommandLine.Parse(args[2:]); err != nil { return err }
+	if flag.NArg() > 0 { return fmt.Errorf("лишние аргументы: %s", strings.Join(flag.Args(), " ")) }
+	dir := *tileCache
+
+	switch op {
+	case "stats":
+		st, err := tiles.ReadCacheStats(dir, *tileTTL)
+		if err != nil { return err }
+		fmt.Printf("кэш %s: %d тайлов, %s\n", dir, st.Tiles, fmtSize(st.Bytes))
+		if *tileTTL > 0 {
+			fmt.Printf("устаревших (TTL %s или max-age сервера): %d\n", *tileTTL, st.Stale)
+		}
+		if !st.Oldest.IsZero() {
+			fmt.Printf("загружены: %s … %s\n", st.Oldest.Format(time.DateTime), st.Newest.Format(time.DateTime))
+		}
+	case "prune":
+		maxBytes, err := parseSize(*tileCacheMax)
+		if err != nil { return fmt.Errorf("tileCacheMax: %w", err) }
+		n, freed, err := tiles.PruneCache(dir, maxBytes, *tileTTL)
+		if err != nil { return err }
+		fmt.Printf("удалено тайлов: %d, освобождено %s\n", n, fmtSize(freed))
+	case "clear":
+		n, err := tiles.ClearCache(dir)
+		if err != nil { return err }
+		fmt.Printf("удалено тайлов: %d\n", n)
+	default:
+		return fmt.Errorf("неизвестная команда %q; %s", op, usage)
+	}
+	return nil
+}
+
+// размер вида 500M, 2G, 64K или в байтах; 0 — без ограничения
+func parseSize(in string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(in))
+	s = strings.TrimSuffix(s, "B")
+	mult := int64(1)
+	if s != "" {
+		switch s[len(s)-1] {
+		case 'K': mult = 1 << 10
+		case 'M': mult = 1 << 20
+		case 'G': mult = 1 << 30
+		}
+		if mult > 1 { s = s[:len(s)-1] }
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 { return 0, fmt.Errorf("неверный размер %q (например 500M, 2G, 0)", in) }
+	return int64(v * float64(mult)), nil
+}
+
+func fmtSize(b int64) string {
+	switch {
+	case b >= 1<<30: return fmt.Sprintf("%.1f ГБ", float64(b)/(1<<30))
+	case b >= 1<<20: return fmt.Sprintf("%.1f МБ", float64(b)/(1<<20))
+	case b >= 1<<10: return fmt.Sprintf("%.1f КБ", float64(b)/(1<<10))
+	}
+	return fmt.Sprintf("%d Б", b)
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want int64
+	}{
+		{"0", 0},
+		{"1024", 1024},
+		{"512K", 512 << 10},
+		{"500M", 500 << 20},
+		{"500mb", 500 << 20},
+		{"2G", 2 << 30},
+		{" 2GB ", 2 << 30},
+		{"1.5G", 3 << 29},
+		{"10B", 10},
+	} {
+		got, err := parseSize(tc.in)
+		if err != nil {
+			t.Errorf("parseSize(%q): %v", tc.in, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("parseSize(%q) = %d, want %d", tc.in, got, tc.want)
+		}
+	}
+	for _, in := range []string{"", "G", "-1M", "10T", "много"} {
+		if _, err := parseSize(in); err == nil {
+			t.Errorf("parseSize(%q): no error", in)
+		}
+	}
+}
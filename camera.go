@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// режимы камеры
+const (
+	cameraFixed   = "fixed"    // весь маршрут целиком, как раньше
+	cameraFollow  = "follow"   // приближенное окно едет за лидером или центром группы
+	cameraZoomOut = "zoom-out" // старт крупно, к концу — весь маршрут
+)
+
+// цель камеры в режиме follow
+const (
+	targetLeader   = "leader"
+	targetCentroid = "centroid"
+)
+
+type CameraOpts struct {
+	Mode   string
+	Zoom   float64 // во сколько раз ближе полного вида
+	Target string  // leader | centroid
+	Smooth float64 // 0..1: какую долю пути до цели камера проходит за кадр
+
+	// подложка под произвольную область; nil — кадрируется общая подложка
+	Base func(ctx context.Context, view boundsLL) (image.Image, error)
+	// вызывается один раз со всеми областями кадров до рендера (подкачка тайлов)
+	Prefetch func(ctx context.Context, views []boundsLL) error
+}
+
+func parseCamera(mode, target string) (string, string, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case "", cameraFixed:
+		mode = cameraFixed
+	case cameraFollow, cameraZoomOut:
+	default:
+		return "", "", fmt.Errorf("неизвестный camera: %q (fixed|follow|zoom-out)", mode)
+	}
+	target = strings.ToLower(strings.TrimSpace(target))
+	if target != targetLeader && target != targetCentroid {
+		return "", "", fmt.Errorf("неизвестный cameraTarget: %q (leader|centroid)", target)
+	}
+	return mode, target, nil
+}
+
+// расставляет View всем кадрам
+func (r *frameRenderer) planCamera(states []frameState) {
+	c := r.opts.Camera
+	if c.Mode == "" || c.Mode == cameraFixed || len(states) == 0 {
+		for i := range states { states[i].View = r.bb }
+		return
+	}
+	zoom := math.Max(1, c.Zoom)
+	bbCLat := (r.bb.minLat + r.bb.maxLat) / 2
+	bbCLon := (r.bb.minLon + r.bb.maxLon) / 2
+
+	switch c.Mode {
+	case cameraFollow:
+		smooth := c.Smooth
+		if smooth <= 0 || smooth > 1 { smooth = 1 }
+		var lat, lon float64
+		for i := range states {
+			tLat, tLon := r.cameraTarget(states[i], c.Target)
+			if i == 0 {
+				lat, lon = tLat, tLon
+			} else {
+				lat += (tLat - lat) * smooth
+				lon += (tLon - lon) * smooth
+			}
+			// сглаживание не должно терять цель: держим её в центральных 60% кадра
+			hLat := (r.bb.maxLat - r.bb.minLat) / zoom * 0.3
+			hLon := (r.bb.maxLon - r.bb.minLon) / zoom * 0.3
+			lat = math.Max(tLat-hLat, math.Min(tLat+hLat, lat))
+			lon = math.Max(tLon-hLon, math.Min(tLon+hLon, lon))
+			states[i].View = r.viewAt(lat, lon, 1/zoom)
+		}
+
+	case cameraZoomOut:
+		// от центра стартов к центру всего маршрута, масштаб от 1/zoom до 1
+		sLat, sLon := r.cameraTarget(states[0], targetCentroid)
+		n := len(states)
+		for i := range states {
+			f := 1.0
+			if n > 1 { f = float64(i) / float64(n-1) }
+			e := f * f * (3 - 2*f) // smoothstep: плавный старт и финиш
+			scale := 1/zoom + (1-1/zoom)*e
+			states[i].View = r.viewAt(sLat+(bbCLat-sLat)*e, sLon+(bbCLon-sLon)*e, scale)
+		}
+	}
+}
+
+// куда смотреть в кадре: голова лидера (дальше всех по дистанции) или центр всех голов
+func (r *frameRenderer) cameraTarget(st frameState, target string) (lat, lon float64) {
+	n := 0
+	best := -1.0
+	for tIdx, pts := range r.tracks {
+		if len(pts) == 0 { continue }
+		i := st.End[tIdx]
+		if i < 0 { i = 0 } // ещё не стартовал — стоит на старте
+		if i > len(pts)-1 { i = len(pts) - 1 }
+		p := pts[i]
+		if target == targetLeader {
+			if d := r.dist[tIdx][i]; d > best {
+				best, lat, lon = d, p.Lat, p.Lon
+			}
+			continue
+		}
+		lat += p.Lat
+		lon += p.Lon
+		n++
+	}
+	if n > 0 {
+		lat /= float64(n)
+		lon /= float64(n)
+	}
+	return lat, lon
+}
+
+// область с центром (lat,lon) и размером scale от общего bbox, прижатая внутрь bbox
+func (r *frameRenderer) viewAt(lat, lon, scale float64) boundsLL {
+	hLat := (r.bb.maxLat - r.bb.minLat) * scale / 2
+	hLon := (r.bb.maxLon - r.bb.minLon) * scale / 2
+	lat = math.Max(r.bb.minLat+hLat, math.Min(r.bb.maxLat-hLat, lat))
+	lon = math.Max(r.bb.minLon+hLon, math.Min(r.bb.maxLon-hLon, lon))
+	return boundsLL{minLat: lat - hLat, maxLat: lat + hLat, minLon: lon - hLon, maxLon: lon + hLon}
+}
+
+// подложка кадра: для неподвижной камеры — общая, иначе под область view
+func (r *frameRenderer) baseFor(ctx context.Context, view boundsLL) (image.Image, error) {
+	c := r.opts.Camera
+	if c.Mode == "" || c.Mode == cameraFixed { return r.base, nil }
+	if r.lastBase != nil && view == r.lastView { return r.lastBase, nil }
+
+	var img image.Image
+	if c.Base != nil {
+		var err error
+		if img, err = c.Base(ctx, view); err != nil { return nil, fmt.Errorf("camera base: %w", err) }
+	} else {
+		img = cropView(r.base, r.bb, view, r.size)
+	}
+	r.lastView, r.lastBase = view, img
+	return img, nil
+}
+
+// вырезает из подложки всего bbox область view и растягивает её на кадр size×size
+func cropView(base image.Image, full, view boundsLL, size int) image.Image {
+	if base == nil { return nil }
+	b := base.Bounds()
+	fx := func(lon float64) float64 { return float64(b.Min.X) + (lon-full.minLon)/(full.maxLon-full.minLon)*float64(b.Dx()) }
+	fy := func(lat float64) float64 { return float64(b.Min.Y) + (full.maxLat-lat)/(full.maxLat-full.minLat)*float64(b.Dy()) }
+	src := image.Rect(
+		int(math.Floor(fx(view.minLon))), int(math.Floor(fy(view.maxLat))),
+		int(math.Ceil(fx(view.maxLon))), int(math.Ceil(fy(view.minLat))),
+	).Intersect(b)
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	if src.Empty() { return dst }
+	xdraw.ApproxBiLinear.Scale(dst, dst.Bounds(), base, src, xdraw.Src, nil)
+	return dst
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/s0ultr4d3r/psstelebot/tiles"
+)
+
+// заставки до и после анимации
+type CardOpts struct {
+	Title      string
+	TitleText  tiles.TextStyle // крупный шрифт заголовка
+	Loc        *time.Location
+	DateFormat string
+	Units      string
+	Intro      time.Duration // 0 — без заставки
+	Outro      time.Duration // 0 — без итогового кадра
+}
+
+// вступление: подложка всего маршрута, заголовок, дата и участники
+func (r *frameRenderer) introCard(ctx context.Context, st frameState) (*image.Paletted, error) {
+	co := r.opts.Cards
+	st.End = make([]int, len(r.tracks))
+	for i := range st.End { st.End[i] = -1 }
+	st.View, st.Card = r.bb, true
+	rgba, err := r.compose(ctx, st)
+	if err != nil { return nil, err }
+
+	ts := r.opts.Text
+	ts.Panel = nil
+	var lines []cardLine
+	if co.Title != "" { lines = append(lines, cardLine{co.Title, co.TitleText}) }
+	if st.HasT && co.DateFormat != "" {
+		loc := co.Loc
+		if loc == nil { loc = time.Local }
+		lines = append(lines, cardLine{r.startT.In(loc).Format(co.DateFormat), ts})
+	}
+	for tIdx := range r.tracks {
+		if tIdx >= len(r.opts.Names) { break }
+		pt := ts
+		pt.Color = r.opts.Colors[tIdx%len(r.opts.Colors)]
+		lines = append(lines, cardLine{r.opts.Names[tIdx], pt})
+	}
+	drawCard(rgba, lines)
+	return quantize(rgba), nil
+}
+
+// итог: весь маршрут целиком и по каждому треку дистанция, время в движении, набор высоты
+func (r *frameRenderer) outroCard(ctx context.Context, st frameState) (*image.Paletted, error) {
+	co := r.opts.Cards
+	st.End = make([]int, len(r.tracks))
+	for i, pts := range r.tracks { st.End[i] = len(pts) - 1 }
+	st.View, st.Card = r.bb, true
+	// итог — маршрут целиком: у comet/ghost остался бы только хвост
+	if r.comet() {
+		style := r.opts.Style
+		r.opts.Style = styleFull
+		defer func() { r.opts.Style = style }()
+	}
+	rgba, err := r.compose(ctx, st)
+	if err != nil { return nil, err }
+
+	ts := r.opts.Text
+	ts.Panel = nil
+	var lines []cardLine
+	if co.Title != "" { lines = append(lines, cardLine{co.Title, co.TitleText}) }
+	for tIdx, pts := range r.tracks {
+		parts := []string{}
+		if tIdx < len(r.opts.Names) { parts = append(parts, r.opts.Names[tIdx]) }
+		if n := len(pts); n > 0 { parts = append(parts, fmtDist(r.dist[tIdx][n-1], co.Units)) }
+		if mt := movingTime(pts, r.dist[tIdx]); mt > 0 { parts = append(parts, fmtSplit(mt)) }
+		if hasEle(pts) { parts = append(parts, "↑"+fmtEle(elevGain(pts), co.Units)) }
+		pt := ts
+		pt.Color = r.opts.Colors[tIdx%len(r.opts.Colors)]
+		lines = append(lines, cardLine{strings.Join(parts, "  "), pt})
+	}
+	drawCard(rgba, lines)
+	return quantize(rgba), nil
+}
+
+type cardLine struct {
+	text string
+	st   tiles.TextStyle
+}
+
+// затемняет кадр и рисует строки по центру на общей плашке
+func drawCard(dst *image.RGBA, lines []cardLine) {
+	b := dst.Bounds()
+	draw.Draw(dst, b, image.NewUniform(color.RGBA{0, 0, 0, 90}), image.Point{}, draw.Over)
+	if len(lines) == 0 { return }
+
+	const gap, pad = 4, 12
+	// длинные строки переносятся по ширине кадра за вычетом полей плашки
+	var wrapped []cardLine
+	for _, l := range lines {
+		for _, s := range wrapText(l.st, l.text, b.Dx()-2*pad) { wrapped = append(wrapped, cardLine{s, l.st}) }
+	}
+	lines = wrapped
+	w, h := 0, -gap
+	for _, l := range lines {
+		lw, lh := l.st.BoxSize(l.text)
+		w = max(w, lw)
+		h += lh + gap
+	}
+	y := b.Min.Y + (b.Dy()-h)/2
+	panel := image.Rect(b.Min.X+(b.Dx()-w)/2-pad, y-pad, b.Min.X+(b.Dx()+w)/2+pad, y+h+pad).Intersect(b)
+	draw.Draw(dst, panel, image.NewUniform(color.RGBA{0, 0, 0, 150}), image.Point{}, draw.Over)
+	for _, l := range lines {
+		lw, lh := l.st.BoxSize(l.text)
+		tiles.DrawText(dst, b.Min.X+(b.Dx()-lw)/2, y, l.text, l.st)
+		y += lh + gap
+	}
+}
+
+// разбивает s на строки не шире maxW: по последнему пробелу, который
+// влезает, а слово шире кадра — по символам
+func wrapText(st tiles.TextStyle, s string, maxW int) []string {
+	var out []string
+	rs := []rune(s)
+	for {
+		if w, _ := st.BoxSize(string(rs)); w <= maxW || len(rs) <= 1 { return append(out, string(rs)) }
+		// самый длинный влезающий префикс, хотя бы один символ
+		n := sort.Search(len(rs), func(i int) bool { w, _ := st.BoxSize(string(rs[:i+1])); return w > maxW })
+		n = max(n, 1)
+		cut := n
+		for k := n; k > 0; k-- {
+			if rs[k] == ' ' { cut = k; break }
+		}
+		out = append(out, strings.TrimRight(string(rs[:cut]), " "))
+		rs = []rune(strings.TrimLeft(string(rs[cut:]), " "))
+		if len(rs) == 0 { return out }
+	}
+}
+
+func hasEle(pts []PtLL) bool {
+	for _, p := range pts {
+		if p.Ele != nil { return true }
+	}
+	return false
+}
+
+// длительность заставки в сотых секунды для задержки кадра GIF
+func cardDelay(d time.Duration) int {
+	cs := int(d / (10 * time.Millisecond))
+	return max(1, min(cs, 65535))
+}
+
+// заголовок: явный, иначе первое <metadata><name> из GPX
+func cardTitle(title string, metaNames []string) string {
+	if title = strings.TrimSpace(title); title != "" { return title }
+	for _, n := range metaNames {
+		if n = strings.TrimSpace(n); n != "" { return n }
+	}
+	return ""
+}
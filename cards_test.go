@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/s0ultr4d3r/psstelebot/tiles"
+)
+
+func TestWrapText(t *testing.T) {
+	face, err := tiles.LoadFace("", 24)
+	if err != nil { t.Fatal(err) }
+	st := tiles.DefaultTextStyle(face)
+	const maxW = 256 - 2*12
+	for _, s := range []string{
+		"",
+		"Коротко",
+		"Вечерний забег по набережной Невы и Летнему саду",
+		"Леша  12.4 км  1:02:03  ↑120 м",
+		"Оченьоченьоченьдлинноеслово без пробелов",
+	} {
+		lines := wrapText(st, s, maxW)
+		for _, l := range lines {
+			if w, _ := st.BoxSize(l); w > maxW { t.Errorf("%q: line %q is %d px wide, max %d", s, l, w, maxW) }
+		}
+		// переносы не теряют текст: только пробелы на стыках
+		if got, want := strings.Join(strings.Fields(strings.Join(lines, "")), ""), strings.Join(strings.Fields(s), ""); got != want {
+			t.Errorf("%q wrapped to %q", s, lines)
+		}
+	}
+	if lines := wrapText(st, "Коротко", maxW); len(lines) != 1 { t.Errorf("short line wrapped: %q", lines) }
+	if lines := wrapText(st, "Вечерний забег по набережной Невы и Летнему саду", maxW); len(lines) < 2 || strings.HasSuffix(lines[0], " ") {
+		t.Errorf("long title: %q", lines)
+	}
+}
@@ -25,7 +25,7 @@ func ParseHexColor(s string) (color.Color, error) {
 		r, _ := strconv.ParseUint(h[2:4], 16, 8)
 		g, _ := strconv.ParseUint(h[4:6], 16, 8)
 		b, _ := strconv.ParseUint(h[6:8], 16, 8)
-		return color.RGBA{uint8(r), uint8(g), uint8(b), uint8(a)}, nil
+		return color.NRGBA{uint8(r), uint8(g), uint8(b), uint8(a)}, nil
 	}
 	return nil, errors.New("hex color формат: #RRGGBB или #AARRGGBB")
 }
@@ -8,6 +8,8 @@ import (
 	"image/color/palette"
 	"math"
 	"time"
+
+	"github.com/s0ultr4d3r/psstelebot/tiles"
 )
 
 // один палетизированный кадр
@@ -16,6 +18,71 @@ type PalFrame struct {
 	Delay int // hundredths of a second
 }
 
+// параметры отрисовки треков
+type RenderOpts struct {
+	Colors []color.Color // цвета линий по трекам (по кругу)
+	Width  int           // толщина линии, px
+
+	// обводка (casing) под линией; OutlineWidth — сколько px добавить с каждой стороны
+	OutlineColors []color.Color
+	OutlineWidth  int
+
+	// мягкая тень под треками
+	Shadow       bool
+	ShadowColor  color.Color
+	ShadowOffset int // сдвиг вправо-вниз, px
+	ShadowBlur   int // радиус размытия, px
+
+	// маркер текущей позиции и подпись к нему
+	Marker     string        // none | circle | arrow | avatar
+	MarkerSize int           // диаметр, px
+	Avatars    []image.Image // для avatar, по трекам
+	Labels     []string      // подписи по трекам, пусто = без подписей
+
+	// стиль текста для подписей и оверлеев
+	Text tiles.TextStyle
+
+	// HUD поверх треков
+	Names []string // имена треков для панелей
+	Clock   ClockOpts
+	Stats   StatsOpts
+	Profile ProfileOpts
+
+	Camera CameraOpts
+
+	Heatmap HeatmapOpts
+	Splits  SplitOpts
+
+	Leaderboard LeaderboardOpts
+
+	Cards   CardOpts
+	Minimap MinimapOpts
+
+	// стиль анимации: full — растущая линия целиком, comet — только хвост
+	// последних TailDur (или TailM метров) с затуханием, ghost — comet поверх
+	// бледного полного маршрута
+	Style      string
+	TailDur    time.Duration
+	TailM      float64 // > 0 — хвост по дистанции, иначе по времени
+	GhostAlpha float64
+}
+
+// стили анимации
+const (
+	styleFull  = "full"
+	styleComet = "comet"
+	styleGhost = "ghost"
+)
+
+// состояние кадра: докуда дорисован каждый трек
+type frameState struct {
+	End  []int     // индекс последней видимой точки; 0 — только старт, < 0 — трек ещё не начался
+	T    time.Time // время кадра (только во временном режиме)
+	HasT bool
+	View boundsLL // область карты в кадре (камера)
+	Card bool     // заставка: без маркеров и HUD
+}
+
 // мульти-рендер: несколько треков, разные цвета
 // СИНХРОНИЗАЦИЯ ПО ВРЕМЕНИ: кадры равномерно покрывают интервал [minT..maxT].
 // Для треков без времени есть фоллбэк по индексу.
@@ -25,8 +92,7 @@ func BuildFramesMulti(
 	sizePx, total int,
 	margin float64,
 	bg color.Color,
-	trackColors []color.Color,
-	trackWidth int,          // ⬅️ новый параметр
+	opts RenderOpts,
 	base image.Image,
 ) ([]*PalFrame, []int, error) {
 
@@ -60,8 +126,19 @@ func BuildFramesMulti(
 		}
 	}
 
-	frames := make([]*PalFrame, 0, total)
-	delays := make([]int, 0, total)
+	r := &frameRenderer{tracks: tracks, bb: bb, size: sizePx, bg: bg, base: base, opts: opts, startT: minT}
+	r.dist = make([][]float64, len(tracks))
+	for i, pts := range tracks { r.dist[i] = cumDist(pts) }
+	// без времени хвост можно мерить только дистанцией
+	if r.comet() && !hasTime && r.opts.TailM <= 0 { r.opts.TailM = 1000 }
+	r.buildProfile(hasTime, minT, maxT)
+	r.buildHeat()
+	r.buildSplits()
+	r.buildCourse()
+	r.buildMinimap()
+
+	// сначала считаем состояния всех кадров, потом по ним — путь камеры, и только потом рисуем
+	var states []frameState
 
 	// Если ни у одного трека нет времени — синхронизация по индексу
 	if !hasTime {
@@ -71,88 +148,285 @@ func BuildFramesMulti(
 		step := math.Max(1, float64(maxPts-1)/float64(total))
 
 		for fi := 0; fi < total; fi++ {
-			select { case <-ctx.Done(): return nil, nil, ctx.Err(); default: }
+			upto := int(math.Round(step*float64(fi+1)))
+			st := frameState{End: make([]int, len(tracks))}
+			for tIdx, pts := range tracks {
+				st.End[tIdx] = min(len(pts)-1, upto)
+			}
+			states = append(states, st)
+		}
+	} else {
+		// Временной режим: кадры равномерно от minT до maxT
+		if total < 2 {
+			total = 2
+		}
+		totalDur := maxT.Sub(minT)
+		cursor := make([]int, len(tracks)) // индекс последней точки <= frameT
 
-			rgba := image.NewRGBA(image.Rect(0, 0, sizePx, sizePx))
-			if base != nil {
-				draw.Draw(rgba, rgba.Bounds(), base, image.Point{}, draw.Src)
+		for fi := 0; fi < total; fi++ {
+			var frameT time.Time
+			if fi == total-1 {
+				frameT = maxT
 			} else {
-				draw.Draw(rgba, rgba.Bounds(), &image.Uniform{C: bg}, image.Point{}, draw.Src)
+				frameT = minT.Add(time.Duration(float64(totalDur) * float64(fi) / float64(total-1)))
 			}
 
-			upto := int(math.Round(step*float64(fi+1)))
-
+			st := frameState{End: make([]int, len(tracks)), T: frameT, HasT: true}
 			for tIdx, pts := range tracks {
+				if len(pts) > 0 && pts[0].T != nil && pts[0].T.After(frameT) {
+					st.End[tIdx] = -1
+					continue
+				}
 				if len(pts) < 2 { continue }
-				endIdx := min(len(pts)-1, upto)
-				col := trackColors[tIdx%len(trackColors)]
-				for i := 0; i < endIdx; i++ {
-					x1, y1 := project(pts[i], bb, sizePx)
-					x2, y2 := project(pts[i+1], bb, sizePx)
-					drawLineRGBA(rgba, x1, y1, x2, y2, trackWidth, col) // ⬅️ толщина
+				i := cursor[tIdx]
+				for i+1 < len(pts) {
+					tNext := pts[i+1].T
+					if tNext == nil || tNext.After(frameT) { break }
+					i++
 				}
+				cursor[tIdx] = i
+				st.End[tIdx] = i
 			}
+			states = append(states, st)
+		}
+	}
 
-			pimg := image.NewPaletted(rgba.Bounds(), palette.Plan9)
-			draw.FloydSteinberg.Draw(pimg, pimg.Bounds(), rgba, image.Point{})
-			frames = append(frames, &PalFrame{Img: pimg, Delay: 5}) // 5 → ~20fps
-			delays = append(delays, 5)
+	// статичная теплокарта — один кадр со всеми треками целиком
+	if opts.Style == styleHeatmapStatic && len(states) > 0 {
+		states = states[len(states)-1:]
+	}
+
+	r.planCamera(states)
+	if r.opts.Camera.Prefetch != nil && r.opts.Camera.Mode != cameraFixed {
+		views := make([]boundsLL, len(states))
+		for i := range states { views[i] = states[i].View }
+		if err := r.opts.Camera.Prefetch(ctx, views); err != nil {
+			return nil, nil, err
 		}
-		return frames, delays, nil
 	}
 
-	// Временной режим: кадры равномерно от minT до maxT
-	if total < 2 {
-		total = 2
+	frames := make([]*PalFrame, 0, len(states)+2)
+	delays := make([]int, 0, len(states)+2)
+	add := func(img *image.Paletted, delay int) {
+		frames = append(frames, &PalFrame{Img: img, Delay: delay})
+		delays = append(delays, delay)
 	}
-	totalDur := maxT.Sub(minT)
-	cursor := make([]int, len(tracks)) // индекс последней точки <= frameT
 
-	for fi := 0; fi < total; fi++ {
+	if opts.Cards.Intro > 0 && len(states) > 0 {
+		img, err := r.introCard(ctx, states[0])
+		if err != nil { return nil, nil, err }
+		add(img, cardDelay(opts.Cards.Intro))
+	}
+	for _, st := range states {
 		select { case <-ctx.Done(): return nil, nil, ctx.Err(); default: }
 
-		var frameT time.Time
-		if fi == total-1 {
-			frameT = maxT
-		} else {
-			frameT = minT.Add(time.Duration(float64(totalDur) * float64(fi) / float64(total-1)))
+		img, err := r.render(ctx, st)
+		if err != nil { return nil, nil, err }
+		add(img, 5) // 5 → ~20fps
+	}
+	if opts.Cards.Outro > 0 && len(states) > 0 {
+		img, err := r.outroCard(ctx, states[len(states)-1])
+		if err != nil { return nil, nil, err }
+		add(img, cardDelay(opts.Cards.Outro))
+	}
+	return frames, delays, nil
+}
+
+// рисует кадры по frameState; общий для индексного и временного режимов
+type frameRenderer struct {
+	tracks [][]PtLL
+	bb     boundsLL
+	size   int
+	bg     color.Color
+	base   image.Image
+	opts   RenderOpts
+	view   boundsLL    // область карты текущего кадра
+	dist   [][]float64 // накопленная дистанция по трекам, м
+	startT time.Time   // начало общего интервала времени
+
+	profile *elevProfile  // статичный слой профиля высот, nil — выключен
+	heat    *heatGrid     // плотность для стилей heatmap, nil — обычные линии
+	splits  [][]splitMark // отметки сплитов по трекам, nil — выключены
+	course  [][]float64   // дистанция вдоль эталонной трассы по точкам, nil — без таблицы гонки
+	leader  int           // лидер текущего кадра, -1 — нет
+	minimap *image.RGBA   // статичный слой врезки-обзора, nil — выключена
+
+	lastView boundsLL // последняя подложка камеры (соседние кадры часто совпадают)
+	lastBase image.Image
+}
+
+func (r *frameRenderer) render(ctx context.Context, st frameState) (*image.Paletted, error) {
+	rgba, err := r.compose(ctx, st)
+	if err != nil { return nil, err }
+	return quantize(rgba), nil
+}
+
+// полноцветный кадр: подложка, треки, маркеры, HUD
+func (r *frameRenderer) compose(ctx context.Context, st frameState) (*image.RGBA, error) {
+	r.view = st.View
+	base, err := r.baseFor(ctx, st.View)
+	if err != nil { return nil, err }
+
+	rgba := image.NewRGBA(image.Rect(0, 0, r.size, r.size))
+	if base != nil {
+		draw.Draw(rgba, rgba.Bounds(), base, image.Point{}, draw.Src)
+	} else {
+		draw.Draw(rgba, rgba.Bounds(), &image.Uniform{C: r.bg}, image.Point{}, draw.Src)
+	}
+
+	if r.heat != nil {
+		r.drawHeat(rgba, st)
+	} else {
+		if r.opts.Style == styleGhost {
+			r.drawGhost(rgba)
 		}
 
-		rgba := image.NewRGBA(image.Rect(0, 0, sizePx, sizePx))
-		if base != nil {
-			draw.Draw(rgba, rgba.Bounds(), base, image.Point{}, draw.Src)
-		} else {
-			draw.Draw(rgba, rgba.Bounds(), &image.Uniform{C: bg}, image.Point{}, draw.Src)
+		// проходы снизу вверх: тень всех треков, обводка всех треков, сами линии —
+		// так пересекающиеся треки не затирают друг друга обводкой.
+		// Линии рисуются в отдельный слой: у хвоста comet своя прозрачность.
+		if r.opts.Shadow {
+			r.drawShadow(rgba, st)
 		}
+		lay := image.NewRGBA(rgba.Bounds())
+		if r.opts.OutlineWidth > 0 && len(r.opts.OutlineColors) > 0 {
+			w := r.opts.Width + 2*r.opts.OutlineWidth
+			r.eachSegment(st, func(tIdx, x1, y1, x2, y2 int, a float64) {
+				drawLineRGBA(lay, x1, y1, x2, y2, w, withAlpha(r.opts.OutlineColors[tIdx%len(r.opts.OutlineColors)], a))
+			})
+		}
+		r.eachSegment(st, func(tIdx, x1, y1, x2, y2 int, a float64) {
+			drawLineRGBA(lay, x1, y1, x2, y2, r.opts.Width, withAlpha(r.opts.Colors[tIdx%len(r.opts.Colors)], a))
+		})
+		draw.Draw(rgba, rgba.Bounds(), lay, image.Point{}, draw.Over)
+	}
+	var order []int
+	r.leader = -1
+	if r.course != nil && !st.Card {
+		order = r.ranking(st)
+		if st.End[order[0]] >= 0 { r.leader = order[0] }
+	}
+	r.drawSplits(rgba, st)
+	if st.Card { return rgba, nil }
+	r.drawHeads(rgba, st)
 
-		for tIdx, pts := range tracks {
-			if len(pts) < 2 { continue }
-			i := cursor[tIdx]
-			for i+1 < len(pts) {
-				tNext := pts[i+1].T
-				if tNext == nil || tNext.After(frameT) { break }
-				i++
-			}
-			cursor[tIdx] = i
+	// оверлеи поверх треков
+	hud := newHUDStack(rgba.Bounds())
+	r.drawProfile(rgba, st)
+	if r.profile != nil {
+		// нижние углы HUD — над полосой профиля
+		hud.used[cornerBL] = r.size - r.profile.layerTop()
+		hud.used[cornerBR] = hud.used[cornerBL]
+	}
+	r.drawMinimap(rgba, hud, st)
+	r.drawClock(rgba, hud, st)
+	r.drawStats(rgba, hud, st)
+	r.drawLeaderboard(rgba, hud, st, order)
+	return rgba, nil
+}
 
-			endIdx := i
-			if endIdx >= len(pts)-1 { endIdx = len(pts)-1 }
-			if endIdx < 1 { continue }
+// палитра Plan9 с дизерингом Флойда–Стейнберга
+func quantize(rgba *image.RGBA) *image.Paletted {
+	pimg := image.NewPaletted(rgba.Bounds(), palette.Plan9)
+	draw.FloydSteinberg.Draw(pimg, pimg.Bounds(), rgba, image.Point{})
+	return pimg
+}
 
-			col := trackColors[tIdx%len(trackColors)]
-			for k := 0; k < endIdx; k++ {
-				x1, y1 := project(pts[k], bb, sizePx)
-				x2, y2 := project(pts[k+1], bb, sizePx)
-				drawLineRGBA(rgba, x1, y1, x2, y2, trackWidth, col) // ⬅️ толщина
-			}
+// точка трека в координатах текущего кадра
+func (r *frameRenderer) pt(p PtLL) (int, int) { return projectFree(p, r.view, r.size) }
+
+// отрезок целиком по одну сторону за краем кадра (с запасом на толщину линии)
+func (r *frameRenderer) offscreen(x1, y1, x2, y2 int) bool {
+	m := r.opts.Width + 2*r.opts.OutlineWidth + r.opts.ShadowOffset + r.opts.ShadowBlur
+	lo, hi := -m, r.size+m
+	return (x1 < lo && x2 < lo) || (x1 > hi && x2 > hi) || (y1 < lo && y2 < lo) || (y1 > hi && y2 > hi)
+}
+
+// обходит видимые сегменты всех треков в экранных координатах, от хвоста к голове;
+// a — непрозрачность сегмента (в стиле full всегда 1)
+func (r *frameRenderer) eachSegment(st frameState, fn func(tIdx, x1, y1, x2, y2 int, a float64)) {
+	for tIdx, pts := range r.tracks {
+		if len(pts) < 2 { continue }
+		endIdx := st.End[tIdx]
+		if endIdx >= len(pts)-1 { endIdx = len(pts)-1 }
+		if endIdx < 1 { continue }
+		for k := r.tailStart(tIdx, endIdx, st); k < endIdx; k++ {
+			a := r.tailAlpha(tIdx, k+1, endIdx, st)
+			if a <= 0 { continue }
+			x1, y1 := r.pt(pts[k])
+			x2, y2 := r.pt(pts[k+1])
+			if r.offscreen(x1, y1, x2, y2) { continue }
+			fn(tIdx, x1, y1, x2, y2, a)
 		}
+	}
+}
 
-		pimg := image.NewPaletted(rgba.Bounds(), palette.Plan9)
-		draw.FloydSteinberg.Draw(pimg, pimg.Bounds(), rgba, image.Point{})
-		frames = append(frames, &PalFrame{Img: pimg, Delay: 5})
-		delays = append(delays, 5)
+// «возраст» точки k относительно головы в долях длины хвоста: 0 — голова, 1 — конец хвоста
+func (r *frameRenderer) tailAge(tIdx, k, endIdx int, st frameState) float64 {
+	pts := r.tracks[tIdx]
+	if r.opts.TailM > 0 || !st.HasT || pts[k].T == nil {
+		tail := r.opts.TailM
+		if tail <= 0 { tail = 1000 }
+		return (r.dist[tIdx][endIdx] - r.dist[tIdx][k]) / tail
 	}
-	return frames, delays, nil
+	if r.opts.TailDur <= 0 { return 0 }
+	return float64(st.T.Sub(*pts[k].T)) / float64(r.opts.TailDur)
+}
+
+func (r *frameRenderer) comet() bool {
+	return r.opts.Style == styleComet || r.opts.Style == styleGhost
+}
+
+func (r *frameRenderer) tailStart(tIdx, endIdx int, st frameState) int {
+	if !r.comet() { return 0 }
+	k := endIdx
+	for k > 0 && r.tailAge(tIdx, k-1, endIdx, st) < 1 { k-- }
+	return k
+}
+
+func (r *frameRenderer) tailAlpha(tIdx, k, endIdx int, st frameState) float64 {
+	if !r.comet() { return 1 }
+	return math.Max(0, 1-r.tailAge(tIdx, k, endIdx, st))
+}
+
+// бледный полный маршрут под кометой
+func (r *frameRenderer) drawGhost(dst *image.RGBA) {
+	if r.opts.GhostAlpha <= 0 { return } // -ghostAlpha 0: только хвост
+	lay := image.NewRGBA(dst.Bounds())
+	for tIdx, pts := range r.tracks {
+		col := r.opts.Colors[tIdx%len(r.opts.Colors)]
+		for k := 0; k+1 < len(pts); k++ {
+			x1, y1 := r.pt(pts[k])
+			x2, y2 := r.pt(pts[k+1])
+			if r.offscreen(x1, y1, x2, y2) { continue }
+			drawLineRGBA(lay, x1, y1, x2, y2, max(1, r.opts.Width/2), col)
+		}
+	}
+	draw.DrawMask(dst, dst.Bounds(), lay, image.Point{}, image.NewUniform(color.Alpha{uint8(math.Round(255 * r.opts.GhostAlpha))}), image.Point{}, draw.Over)
+}
+
+// цвет с непрозрачностью, умноженной на a
+func withAlpha(c color.Color, a float64) color.Color {
+	if a >= 1 { return c }
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	n.A = uint8(float64(n.A) * a)
+	return n
+}
+
+// тень: маска всех линий (с обводкой), размытие, смещение и заливка цветом тени
+func (r *frameRenderer) drawShadow(dst *image.RGBA, st frameState) {
+	w := r.opts.Width
+	if len(r.opts.OutlineColors) > 0 { w += 2 * r.opts.OutlineWidth }
+	mask := image.NewAlpha(dst.Bounds())
+	r.eachSegment(st, func(_, x1, y1, x2, y2 int, a float64) {
+		v := uint8(255 * a)
+		walkLine(x1, y1, x2, y2, func(x, y int) { plotSquareAlpha(mask, x, y, w, v) })
+	})
+	boxBlurAlpha(mask, r.opts.ShadowBlur)
+
+	off := image.Pt(r.opts.ShadowOffset, r.opts.ShadowOffset)
+	sc := r.opts.ShadowColor
+	if sc == nil { sc = color.RGBA{0, 0, 0, 160} }
+	draw.DrawMask(dst, dst.Bounds().Add(off), &image.Uniform{C: sc}, image.Point{}, mask, image.Point{}, draw.Over)
 }
 
 
@@ -174,29 +448,38 @@ func bboxLL(pts []PtLL) boundsLL {
 }
 
 func project(p PtLL, bb boundsLL, size int) (x, y int) {
+	xx, yy := projectFree(p, bb, size)
+	if xx < 0 { xx = 0 }
+	if xx >= size { xx = size - 1 }
+	if yy < 0 { yy = 0 }
+	if yy >= size { yy = size - 1 }
+	return xx, yy
+}
+
+// как project, но без прижатия к краям: точки вне bb уходят за кадр (нужно камере)
+func projectFree(p PtLL, bb boundsLL, size int) (x, y int) {
 	spanLat := bb.maxLat - bb.minLat
 	spanLon := bb.maxLon - bb.minLon
 	xf := 0.0
 	if spanLon > 0 { xf = (p.Lon - bb.minLon) / spanLon }
 	yf := 0.0
 	if spanLat > 0 { yf = 1.0 - (p.Lat-bb.minLat)/spanLat }
-	xx := int(math.Round(xf * float64(size-1)))
-	yy := int(math.Round(yf * float64(size-1)))
-	if xx < 0 { xx = 0 }
-	if xx >= size { xx = size - 1 }
-	if yy < 0 { yy = 0 }
-	if yy >= size { yy = size - 1 }
-	return xx, yy
+	return int(math.Round(xf * float64(size-1))), int(math.Round(yf * float64(size-1)))
 }
 
 func drawLineRGBA(img *image.RGBA, x0, y0, x1, y1, width int, c color.Color) {
+	walkLine(x0, y0, x1, y1, func(x, y int) { plotSquareRGBA(img, x, y, width, c) })
+}
+
+// Брезенхэм: вызывает plot для каждой точки отрезка
+func walkLine(x0, y0, x1, y1 int, plot func(x, y int)) {
 	dx := int(math.Abs(float64(x1 - x0)))
 	sx := -1; if x0 < x1 { sx = 1 }
 	dy := -int(math.Abs(float64(y1 - y0)))
 	sy := -1; if y0 < y1 { sy = 1 }
 	err := dx + dy
 	for {
-		plotSquareRGBA(img, x0, y0, width, c)
+		plot(x0, y0)
 		if x0 == x1 && y0 == y1 { break }
 		e2 := 2 * err
 		if e2 >= dy { err += dy; x0 += sx }
@@ -217,4 +500,47 @@ func plotSquareRGBA(img *image.RGBA, cx, cy, w int, c color.Color) {
 	}
 }
 
+func plotSquareAlpha(img *image.Alpha, cx, cy, w int, v uint8) {
+	r := 0
+	if w > 1 { r = (w - 1) / 2 }
+	for y := cy - r; y <= cy+r; y++ {
+		for x := cx - r; x <= cx+r; x++ {
+			if !image.Pt(x, y).In(img.Rect) { continue }
+			if i := img.PixOffset(x, y); img.Pix[i] < v { img.Pix[i] = v }
+		}
+	}
+}
+
+// box blur маски: два прохода (горизонталь, вертикаль), радиус r
+func boxBlurAlpha(m *image.Alpha, r int) {
+	if r <= 0 { return }
+	b := m.Bounds()
+	w, h := b.Dx(), b.Dy()
+	tmp := make([]uint8, len(m.Pix))
+	win := 2*r + 1
+	for y := 0; y < h; y++ {
+		row := m.Pix[y*m.Stride:]
+		sum := 0
+		for x := -r; x <= r; x++ { sum += int(row[clampInt(x, 0, w-1)]) }
+		for x := 0; x < w; x++ {
+			tmp[y*m.Stride+x] = uint8(sum / win)
+			sum += int(row[clampInt(x+r+1, 0, w-1)]) - int(row[clampInt(x-r, 0, w-1)])
+		}
+	}
+	for x := 0; x < w; x++ {
+		sum := 0
+		for y := -r; y <= r; y++ { sum += int(tmp[clampInt(y, 0, h-1)*m.Stride+x]) }
+		for y := 0; y < h; y++ {
+			m.Pix[y*m.Stride+x] = uint8(sum / win)
+			sum += int(tmp[clampInt(y+r+1, 0, h-1)*m.Stride+x]) - int(tmp[clampInt(y-r, 0, h-1)*m.Stride+x])
+		}
+	}
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo { return lo }
+	if v > hi { return hi }
+	return v
+}
+
 func min(a, b int) int { if a < b { return a }; return b }
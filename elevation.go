@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"time"
+
+	"github.com/s0ultr4d3r/psstelebot/tiles"
+)
+
+// профиль высот внизу кадра
+type ProfileOpts struct {
+	Enabled bool
+	Height  int  // высота полосы, px
+	ByTime  bool // ось X — время, иначе дистанция
+	Bottom  int  // отступ снизу, px (чтобы не закрывать атрибуцию карты)
+}
+
+// профиль рисуется один раз в статичный слой; по кадрам двигается только курсор
+type elevProfile struct {
+	layer      *image.RGBA
+	panel      image.Rectangle // плашка профиля
+	rect       image.Rectangle // область графика внутри плашки
+	minE, maxE float64
+	maxD       float64       // дистанция самого длинного трека, м
+	t0         time.Time
+	span       time.Duration // ось времени
+	byTime     bool
+}
+
+func (r *frameRenderer) buildProfile(hasTime bool, minT, maxT time.Time) {
+	po := r.opts.Profile
+	if !po.Enabled { return }
+	h := po.Height
+	if h < 40 { h = 40 }
+
+	p := &elevProfile{minE: math.MaxFloat64, maxE: -math.MaxFloat64, t0: minT, span: maxT.Sub(minT), byTime: po.ByTime && hasTime}
+	for tIdx, pts := range r.tracks {
+		for _, pt := range pts {
+			if pt.Ele == nil { continue }
+			p.minE = math.Min(p.minE, *pt.Ele)
+			p.maxE = math.Max(p.maxE, *pt.Ele)
+		}
+		if n := len(pts); n > 0 { p.maxD = math.Max(p.maxD, r.dist[tIdx][n-1]) }
+	}
+	if p.minE > p.maxE { return } // ни у одного трека нет <ele>
+	if p.maxE-p.minE < 10 { p.maxE = p.minE + 10 }
+	if p.maxD <= 0 { p.maxD = 1 }
+	if p.span <= 0 { p.span = time.Second }
+
+	panel := image.Rect(6, r.size-po.Bottom-6-h, r.size-6, r.size-po.Bottom-6)
+	p.panel = panel
+	p.layer = image.NewRGBA(image.Rect(0, 0, r.size, r.size))
+	draw.Draw(p.layer, panel, image.NewUniform(color.RGBA{0, 0, 0, 140}), image.Point{}, draw.Src)
+
+	// подписи min/max высоты слева, график справа от них
+	ts := r.opts.Text
+	ts.Panel = nil
+	ts.Padding = 1
+	top := fmt.Sprintf("%.0f", p.maxE)
+	bot := fmt.Sprintf("%.0f", p.minE)
+	lw, lh := ts.BoxSize(top)
+	if w, _ := ts.BoxSize(bot); w > lw { lw = w }
+	tiles.DrawText(p.layer, panel.Min.X+2, panel.Min.Y+2, top, ts)
+	tiles.DrawText(p.layer, panel.Min.X+2, panel.Max.Y-lh-2, bot, ts)
+	p.rect = image.Rect(panel.Min.X+lw+6, panel.Min.Y+6, panel.Max.X-6, panel.Max.Y-6)
+
+	for tIdx, pts := range r.tracks {
+		col := r.opts.Colors[tIdx%len(r.opts.Colors)]
+		px, py, have := 0, 0, false
+		for i := range pts {
+			x, y, ok := p.xy(r, tIdx, i)
+			if !ok { have = false; continue }
+			if have { drawLineRGBA(p.layer, px, py, x, y, 2, col) }
+			px, py, have = x, y, true
+		}
+	}
+	r.profile = p
+}
+
+// верхняя граница плашки в координатах кадра
+func (p *elevProfile) layerTop() int { return p.panel.Min.Y - 6 }
+
+// точка i трека tIdx в координатах кадра
+func (p *elevProfile) xy(r *frameRenderer, tIdx, i int) (int, int, bool) {
+	pt := r.tracks[tIdx][i]
+	if pt.Ele == nil { return 0, 0, false }
+	var fx float64
+	if p.byTime {
+		if pt.T == nil { return 0, 0, false }
+		fx = float64(pt.T.Sub(p.t0)) / float64(p.span)
+	} else {
+		fx = r.dist[tIdx][i] / p.maxD
+	}
+	fy := (*pt.Ele - p.minE) / (p.maxE - p.minE)
+	x := p.rect.Min.X + int(math.Round(fx*float64(p.rect.Dx()-1)))
+	y := p.rect.Max.Y - 1 - int(math.Round(fy*float64(p.rect.Dy()-1)))
+	return x, y, true
+}
+
+// статичный слой профиля + курсоры текущего кадра
+func (r *frameRenderer) drawProfile(dst *image.RGBA, st frameState) {
+	p := r.profile
+	if p == nil { return }
+	draw.Draw(dst, p.panel, p.layer, p.panel.Min, draw.Over)
+
+	if p.byTime && st.HasT {
+		fx := float64(st.T.Sub(p.t0)) / float64(p.span)
+		x := p.rect.Min.X + int(math.Round(fx*float64(p.rect.Dx()-1)))
+		drawLineRGBA(dst, x, p.rect.Min.Y, x, p.rect.Max.Y-1, 1, color.RGBA{255, 255, 255, 200})
+	}
+	for tIdx, pts := range r.tracks {
+		i := st.End[tIdx]
+		if i < 0 || len(pts) == 0 { continue }
+		if i > len(pts)-1 { i = len(pts) - 1 }
+		x, y, ok := p.xy(r, tIdx, i)
+		if !ok { continue }
+		fillDisc(dst, x, y, 4, color.White)
+		fillDisc(dst, x, y, 3, r.opts.Colors[tIdx%len(r.opts.Colors)])
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+const earthRadiusM = 6371008.8
+
+// расстояние по большому кругу, метры
+func haversine(a, b PtLL) float64 {
+	la1 := a.Lat * math.Pi / 180
+	la2 := b.Lat * math.Pi / 180
+	dLat := la2 - la1
+	dLon := (b.Lon - a.Lon) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(la1)*math.Cos(la2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusM * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// накопленная дистанция до каждой точки трека, метры
+func cumDist(pts []PtLL) []float64 {
+	out := make([]float64, len(pts))
+	for i := 1; i < len(pts); i++ {
+		out[i] = out[i-1] + haversine(pts[i-1], pts[i])
+	}
+	return out
+}
+
+// минимальная скорость, при которой трек считается «в движении», м/с
+const movingSpeed = 0.5
+
+// время в движении: сумма интервалов между точками со скоростью не ниже movingSpeed
+func movingTime(pts []PtLL, dist []float64) time.Duration {
+	var d time.Duration
+	for i := 1; i < len(pts); i++ {
+		if pts[i].T == nil || pts[i-1].T == nil { continue }
+		dt := pts[i].T.Sub(*pts[i-1].T)
+		if dt <= 0 { continue }
+		if (dist[i]-dist[i-1])/dt.Seconds() >= movingSpeed { d += dt }
+	}
+	return d
+}
+
+// набор высоты, м; подъёмы меньше порога считаются шумом GPS
+func elevGain(pts []PtLL) float64 {
+	const noise = 3.0
+	gain := 0.0
+	ref := math.NaN()
+	for _, p := range pts {
+		if p.Ele == nil { continue }
+		e := *p.Ele
+		switch {
+		case math.IsNaN(ref) || e < ref:
+			ref = e
+		case e-ref >= noise:
+			gain += e - ref
+			ref = e
+		}
+	}
+	return gain
+}
@@ -2,13 +2,29 @@ module github.com/s0ultr4d3r/psstelebot
 
 go 1.25.1
 
-require github.com/schollz/progressbar/v3 v3.18.0
+require (
+	github.com/schollz/progressbar/v3 v3.18.0
+	modernc.org/sqlite v1.46.1
+)
+
+require (
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/ncruces/go-strftime v1.0.0 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	golang.org/x/exp v0.0.0-20251023183803-a4bb9ffd2546 // indirect
+	golang.org/x/text v0.29.0 // indirect
+	modernc.org/libc v1.67.6 // indirect
+	modernc.org/mathutil v1.7.1 // indirect
+	modernc.org/memory v1.11.0 // indirect
+)
 
 require (
 	github.com/mitchellh/colorstring v0.0.0-20190213212951-d06e56a500db // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
-	golang.org/x/image v0.31.0 // indirect
-	golang.org/x/sys v0.29.0 // indirect
+	golang.org/x/image v0.31.0
+	golang.org/x/sys v0.37.0 // indirect
 	golang.org/x/term v0.28.0 // indirect
 	golang.org/x/time v0.13.0
 )
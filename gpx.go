@@ -4,6 +4,8 @@ import (
 	"encoding/xml"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -11,13 +13,27 @@ type PtLL struct {
 	Lat float64
 	Lon float64
 	T   *time.Time
+	Ele *float64 // высота, м
+	HR  int      // пульс, уд/мин; 0 — нет данных
+}
+
+// трек целиком: точки + имя
+type GPXTrack struct {
+	Name     string // <trk><name>, иначе <metadata><name>, иначе имя файла
+	MetaName string // <metadata><name>
+	Pts      []PtLL
 }
 
 type gpxFile struct {
-	Trk []trk `xml:"trk"`
+	Meta gpxMeta `xml:"metadata"`
+	Trk  []trk   `xml:"trk"`
+}
+type gpxMeta struct {
+	Name string `xml:"name"`
 }
 type trk struct {
-	Seg []trkseg `xml:"trkseg"`
+	Name string   `xml:"name"`
+	Seg  []trkseg `xml:"trkseg"`
 }
 type trkseg struct {
 	Pt []wpt `xml:"trkpt"`
@@ -25,10 +41,18 @@ type trkseg struct {
 type wpt struct {
 	Lat  float64    `xml:"lat,attr"`
 	Lon  float64    `xml:"lon,attr"`
+	Ele  *float64   `xml:"ele"`
 	Time *time.Time `xml:"time"`
+	HR   int        `xml:"extensions>TrackPointExtension>hr"` // Garmin gpxtpx
 }
 
 func ParseGPXFile(path string) ([]PtLL, error) {
+	t, err := ParseGPX(path)
+	if err != nil { return nil, err }
+	return t.Pts, nil
+}
+
+func ParseGPX(path string) (*GPXTrack, error) {
 	f, err := os.Open(path)
 	if err != nil { return nil, fmt.Errorf("open: %w", err) }
 	defer f.Close()
@@ -37,13 +61,16 @@ func ParseGPXFile(path string) ([]PtLL, error) {
 	if err := xml.NewDecoder(f).Decode(&g); err != nil {
 		return nil, fmt.Errorf("decode: %w", err)
 	}
-	out := make([]PtLL, 0, 1024)
+	out := &GPXTrack{MetaName: strings.TrimSpace(g.Meta.Name), Pts: make([]PtLL, 0, 1024)}
 	for _, tr := range g.Trk {
+		if out.Name == "" { out.Name = strings.TrimSpace(tr.Name) }
 		for _, s := range tr.Seg {
 			for _, p := range s.Pt {
-				out = append(out, PtLL{Lat: p.Lat, Lon: p.Lon, T: p.Time})
+				out.Pts = append(out.Pts, PtLL{Lat: p.Lat, Lon: p.Lon, T: p.Time, Ele: p.Ele, HR: p.HR})
 			}
 		}
 	}
+	if out.Name == "" { out.Name = out.MetaName }
+	if out.Name == "" { out.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) }
 	return out, nil
 }
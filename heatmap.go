@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// стили теплокарты: heatmap — плотность копится по мере анимации,
+// heatmap-static — один кадр с плотностью по всем трекам целиком
+const (
+	styleHeatmap       = "heatmap"
+	styleHeatmapStatic = "heatmap-static"
+)
+
+// палитры теплокарты: опорные цвета от редких к частым участкам
+var heatColormaps = map[string][]color.NRGBA{
+	"hot": {
+		{80, 0, 120, 0}, {160, 0, 160, 160}, {230, 30, 40, 210}, {255, 140, 0, 235}, {255, 230, 60, 250}, {255, 255, 255, 255},
+	},
+	"viridis": {
+		{68, 1, 84, 0}, {59, 82, 139, 170}, {33, 145, 140, 210}, {94, 201, 98, 235}, {253, 231, 37, 255},
+	},
+}
+
+type HeatmapOpts struct {
+	Colormap string
+	Radius   int // радиус размытия «свечения», px
+}
+
+func parseColormap(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if _, ok := heatColormaps[s]; !ok {
+		return "", fmt.Errorf("неизвестная палитра heatmap: %q (hot|viridis)", s)
+	}
+	return s, nil
+}
+
+func (r *frameRenderer) heatmapStyle() bool {
+	return r.opts.Style == styleHeatmap || r.opts.Style == styleHeatmapStatic
+}
+
+// плотность треков на сетке кадра; каждый трек добавляет в пиксель не больше единицы,
+// так что ярче те участки, где проехало больше разных треков
+type heatGrid struct {
+	size    int
+	grid    []float32
+	visited [][]uint64 // по трекам: битовая маска уже учтённых пикселей
+	cursor  []int      // по трекам: сколько сегментов уже растеризовано
+	norm    float64    // log(1+max) итоговой сетки — яркость растёт, а не пересчитывается
+	lut     [256]color.NRGBA
+}
+
+func (r *frameRenderer) buildHeat() {
+	if !r.heatmapStyle() { return }
+	h := newHeatGrid(r.size, len(r.tracks))
+
+	// итоговая плотность по всем трекам нужна заранее для нормировки
+	for tIdx, pts := range r.tracks { h.advance(r, tIdx, len(pts)-1) }
+	peak := 1.0
+	for _, v := range blurGrid(h.grid, r.size, r.opts.Heatmap.Radius) { peak = math.Max(peak, float64(v)) }
+	h.norm = math.Log1p(peak)
+	h.reset()
+
+	cm := heatColormaps[r.opts.Heatmap.Colormap]
+	if cm == nil { cm = heatColormaps["hot"] }
+	for i := range h.lut { h.lut[i] = sampleColormap(cm, float64(i)/255) }
+	r.heat = h
+}
+
+func newHeatGrid(size, n int) *heatGrid {
+	h := &heatGrid{size: size, grid: make([]float32, size*size), visited: make([][]uint64, n), cursor: make([]int, n)}
+	for i := range h.visited { h.visited[i] = make([]uint64, (size*size+63)/64) }
+	return h
+}
+
+func (h *heatGrid) reset() {
+	clear(h.grid)
+	for i := range h.visited { clear(h.visited[i]) }
+	clear(h.cursor)
+}
+
+// растеризует сегменты трека до точки upto, которых ещё не было
+func (h *heatGrid) advance(r *frameRenderer, tIdx, upto int) {
+	pts := r.tracks[tIdx]
+	if upto > len(pts)-1 { upto = len(pts) - 1 }
+	w := max(1, r.opts.Width)
+	rad := (w - 1) / 2
+	vis := h.visited[tIdx]
+	for k := h.cursor[tIdx]; k < upto; k++ {
+		x1, y1 := projectFree(pts[k], r.bb, h.size)
+		x2, y2 := projectFree(pts[k+1], r.bb, h.size)
+		walkLine(x1, y1, x2, y2, func(cx, cy int) {
+			for y := cy - rad; y <= cy+rad; y++ {
+				if y < 0 || y >= h.size { continue }
+				for x := cx - rad; x <= cx+rad; x++ {
+					if x < 0 || x >= h.size { continue }
+					i := y*h.size + x
+					if vis[i/64]&(1<<(i%64)) == 0 { vis[i/64] |= 1 << (i % 64); h.grid[i]++ }
+				}
+			}
+		})
+	}
+	if upto > h.cursor[tIdx] { h.cursor[tIdx] = upto }
+}
+
+// накапливает плотность до текущего кадра и накладывает тонированный слой
+func (r *frameRenderer) drawHeat(dst *image.RGBA, st frameState) {
+	h := r.heat
+	for tIdx := range r.tracks {
+		if st.End[tIdx] >= 1 { h.advance(r, tIdx, st.End[tIdx]) }
+	}
+	g := blurGrid(h.grid, h.size, r.opts.Heatmap.Radius)
+	for y := 0; y < h.size; y++ {
+		for x := 0; x < h.size; x++ {
+			v := g[y*h.size+x]
+			if v <= 0 { continue }
+			t := math.Log1p(float64(v)) / h.norm
+			c := h.lut[int(math.Min(1, t)*255)]
+			if c.A == 0 { continue }
+			blendNRGBA(dst, x, y, c)
+		}
+	}
+}
+
+// размытие сетки box-фильтром (два прохода), исходная сетка не меняется
+func blurGrid(src []float32, size, r int) []float32 {
+	if r <= 0 { return src }
+	tmp := make([]float32, len(src))
+	out := make([]float32, len(src))
+	win := float32(2*r + 1)
+	for y := 0; y < size; y++ {
+		row := src[y*size : (y+1)*size]
+		var sum float32
+		for x := -r; x <= r; x++ { sum += row[clampInt(x, 0, size-1)] }
+		for x := 0; x < size; x++ {
+			tmp[y*size+x] = sum / win
+			sum += row[clampInt(x+r+1, 0, size-1)] - row[clampInt(x-r, 0, size-1)]
+		}
+	}
+	for x := 0; x < size; x++ {
+		var sum float32
+		for y := -r; y <= r; y++ { sum += tmp[clampInt(y, 0, size-1)*size+x] }
+		for y := 0; y < size; y++ {
+			out[y*size+x] = sum / win
+			sum += tmp[clampInt(y+r+1, 0, size-1)*size+x] - tmp[clampInt(y-r, 0, size-1)*size+x]
+		}
+	}
+	return out
+}
+
+// линейная интерполяция по опорным цветам палитры, t в [0..1]
+func sampleColormap(cm []color.NRGBA, t float64) color.NRGBA {
+	if t <= 0 { return cm[0] }
+	if t >= 1 { return cm[len(cm)-1] }
+	f := t * float64(len(cm)-1)
+	i := int(f)
+	a, b := cm[i], cm[i+1]
+	k := f - float64(i)
+	lerp := func(x, y uint8) uint8 { return uint8(float64(x) + (float64(y)-float64(x))*k) }
+	return color.NRGBA{lerp(a.R, b.R), lerp(a.G, b.G), lerp(a.B, b.B), lerp(a.A, b.A)}
+}
+
+// source-over одного пикселя неперемноженным цветом
+func blendNRGBA(dst *image.RGBA, x, y int, c color.NRGBA) {
+	i := dst.PixOffset(x, y)
+	a := uint32(c.A)
+	ia := 255 - a
+	p := dst.Pix[i : i+4 : i+4]
+	p[0] = uint8((uint32(c.R)*a + uint32(p[0])*ia) / 255)
+	p[1] = uint8((uint32(c.G)*a + uint32(p[1])*ia) / 255)
+	p[2] = uint8((uint32(c.B)*a + uint32(p[2])*ia) / 255)
+	p[3] = uint8(a + uint32(p[3])*ia/255)
+}
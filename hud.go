@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"time"
+
+	"github.com/s0ultr4d3r/psstelebot/tiles"
+)
+
+// углы кадра для оверлеев
+const (
+	cornerTL = "tl"
+	cornerTR = "tr"
+	cornerBL = "bl"
+	cornerBR = "br"
+)
+
+func parseCorner(s string) (string, error) {
+	switch s = strings.ToLower(strings.TrimSpace(s)); s {
+	case cornerTL, cornerTR, cornerBL, cornerBR:
+		return s, nil
+	}
+	return "", fmt.Errorf("неизвестный угол: %q (tl|tr|bl|br)", s)
+}
+
+// раскладка оверлеев по углам: блоки в одном углу складываются стопкой,
+// сверху вниз в верхних углах и снизу вверх в нижних
+type hudStack struct {
+	bounds image.Rectangle
+	margin int
+	used   map[string]int
+}
+
+func newHUDStack(b image.Rectangle) *hudStack {
+	return &hudStack{bounds: b, margin: 6, used: map[string]int{}}
+}
+
+// резервирует место w×h в углу и возвращает левый верхний угол блока
+func (s *hudStack) place(corner string, w, h int) image.Point {
+	b := s.bounds
+	off := s.used[corner]
+	s.used[corner] = off + h + 2
+
+	x := b.Min.X + s.margin
+	if corner == cornerTR || corner == cornerBR {
+		x = b.Max.X - s.margin - w
+	}
+	y := b.Min.Y + s.margin + off
+	if corner == cornerBL || corner == cornerBR {
+		y = b.Max.Y - s.margin - off - h
+	}
+	return image.Pt(x, y)
+}
+
+// рисует строки текста стопкой в углу, выравнивая по краю угла
+func (s *hudStack) drawLines(dst *image.RGBA, corner string, lines []string, st tiles.TextStyle) {
+	if corner == cornerBL || corner == cornerBR {
+		// снизу вверх, чтобы первая строка оказалась верхней
+		for i := len(lines) - 1; i >= 0; i-- {
+			w, h := st.BoxSize(lines[i])
+			p := s.place(corner, w, h)
+			tiles.DrawText(dst, p.X, p.Y, lines[i], st)
+		}
+		return
+	}
+	for _, l := range lines {
+		w, h := st.BoxSize(l)
+		p := s.place(corner, w, h)
+		tiles.DrawText(dst, p.X, p.Y, l, st)
+	}
+}
+
+// часы: настенное время кадра, дата и время с начала
+type ClockOpts struct {
+	Enabled    bool
+	Loc        *time.Location
+	Corner     string
+	TimeFormat string // layout Go, например 15:04:05
+	DateFormat string // пусто — без даты
+	Elapsed    bool
+}
+
+func (r *frameRenderer) drawClock(dst *image.RGBA, hud *hudStack, st frameState) {
+	c := r.opts.Clock
+	if !c.Enabled || !st.HasT { return }
+	loc := c.Loc
+	if loc == nil { loc = time.Local }
+	t := st.T.In(loc)
+
+	var lines []string
+	if c.TimeFormat != "" { lines = append(lines, t.Format(c.TimeFormat)) }
+	if c.DateFormat != "" { lines = append(lines, t.Format(c.DateFormat)) }
+	if c.Elapsed { lines = append(lines, "+"+fmtElapsed(st.T.Sub(r.startT))) }
+	hud.drawLines(dst, c.Corner, lines, r.opts.Text)
+}
+
+// h:mm:ss
+func fmtElapsed(d time.Duration) string {
+	if d < 0 { d = 0 }
+	s := int(d.Round(time.Second) / time.Second)
+	return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/s0ultr4d3r/psstelebot/tiles"
+)
+
+// таблица гонки: места по дистанции вдоль трассы и отставание от лидера
+type LeaderboardOpts struct {
+	Enabled bool
+	Corner  string
+	Ref     int  // индекс трека-эталона трассы
+	GapTime bool // отставание по времени, иначе по дистанции
+	Units   string
+}
+
+// индекс трека-эталона по номеру файла в -in (с 1); inIdx — номер файла
+// для каждого загруженного трека, пустые GPX в нём пропущены
+func courseRefTrack(n int, inPaths []string, inIdx []int) (int, error) {
+	if n < 1 || n > len(inPaths) { return 0, fmt.Errorf("courseRef: нет трека %d (всего %d)", n, len(inPaths)) }
+	for tIdx, k := range inIdx {
+		if k == n-1 { return tIdx, nil }
+	}
+	return 0, fmt.Errorf("courseRef: в %s нет точек, выберите другой трек-эталон", inPaths[n-1])
+}
+
+// насколько далеко вперёд по трассе искать проекцию следующей точки, м
+const courseLookahead = 500
+
+// дистанция вдоль трассы для каждой точки каждого трека: точка проецируется
+// на ближайший сегмент эталона рядом с предыдущей проекцией, а результат
+// не убывает — шум GPS не двигает гонщика назад и не переставляет места
+func (r *frameRenderer) buildCourse() {
+	lo := r.opts.Leaderboard
+	if !lo.Enabled { return }
+	ref := r.tracks[lo.Ref]
+	refD := r.dist[lo.Ref]
+	if len(ref) < 2 { return }
+
+	// локальная равнопромежуточная проекция в метрах
+	lat0 := (r.bb.minLat + r.bb.maxLat) / 2 * math.Pi / 180
+	xy := func(p PtLL) (float64, float64) {
+		return p.Lon * math.Pi / 180 * math.Cos(lat0) * earthRadiusM, p.Lat * math.Pi / 180 * earthRadiusM
+	}
+	rx := make([]float64, len(ref))
+	ry := make([]float64, len(ref))
+	for i, p := range ref { rx[i], ry[i] = xy(p) }
+
+	// ближайшая точка на сегментах [from..to): дистанция вдоль трассы
+	nearest := func(x, y float64, from, to int) float64 {
+		bestD, best2 := 0.0, math.MaxFloat64
+		for k := from; k < to; k++ {
+			dx, dy := rx[k+1]-rx[k], ry[k+1]-ry[k]
+			f := 0.0
+			if l2 := dx*dx + dy*dy; l2 > 0 { f = math.Max(0, math.Min(1, ((x-rx[k])*dx+(y-ry[k])*dy)/l2)) }
+			ex, ey := rx[k]+dx*f-x, ry[k]+dy*f-y
+			if d2 := ex*ex + ey*ey; d2 < best2 { best2, bestD = d2, refD[k]+(refD[k+1]-refD[k])*f }
+		}
+		return bestD
+	}
+
+	r.course = make([][]float64, len(r.tracks))
+	for tIdx, pts := range r.tracks {
+		c := make([]float64, len(pts))
+		seg := 0 // сегмент эталона у предыдущей проекции
+		for i, p := range pts {
+			x, y := xy(p)
+			if i == 0 {
+				// старт ищем в начале трассы: на кольце финиш совпадает со стартом
+				to := 1
+				for to < len(ref)-1 && refD[to] < 4*courseLookahead { to++ }
+				c[i] = nearest(x, y, 0, to)
+			} else {
+				// окно поиска: немного назад и вперёд на пройденное с прошлой точки плюс запас
+				ahead := c[i-1] + (r.dist[tIdx][i] - r.dist[tIdx][i-1]) + courseLookahead
+				from := max(0, seg-1)
+				to := seg
+				for to < len(ref)-1 && refD[to] < ahead { to++ }
+				d := nearest(x, y, from, max(to, from+1))
+				c[i] = math.Max(c[i-1], d)
+			}
+			for seg+1 < len(ref)-1 && refD[seg+1] <= c[i] { seg++ }
+		}
+		r.course[tIdx] = c
+	}
+}
+
+// места в кадре: индексы треков от лидера; не стартовавшие — в конце
+func (r *frameRenderer) ranking(st frameState) []int {
+	order := make([]int, 0, len(r.tracks))
+	for tIdx := range r.tracks { order = append(order, tIdx) }
+	pos := func(tIdx int) float64 {
+		i := st.End[tIdx]
+		if i < 0 || len(r.course[tIdx]) == 0 { return -1 }
+		return r.course[tIdx][min(i, len(r.course[tIdx])-1)]
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		// оба финишировали — выше тот, кто раньше
+		if fa, ok := r.finishedAt(order[a], st); ok {
+			if fb, ok := r.finishedAt(order[b], st); ok { return fa.Before(fb) }
+		}
+		return pos(order[a]) > pos(order[b])
+	})
+	return order
+}
+
+// момент, когда трек tIdx был в точке d трассы (интерполяция между точками)
+func (r *frameRenderer) timeAtCourse(tIdx int, d float64) (time.Time, bool) {
+	c := r.course[tIdx]
+	pts := r.tracks[tIdx]
+	k := sort.SearchFloat64s(c, d)
+	if k >= len(c) || pts[k].T == nil { return time.Time{}, false }
+	if k == 0 || pts[k-1].T == nil || c[k] == c[k-1] { return *pts[k].T, true }
+	f := (d - c[k-1]) / (c[k] - c[k-1])
+	return pts[k-1].T.Add(time.Duration(float64(pts[k].T.Sub(*pts[k-1].T)) * f)), true
+}
+
+func (r *frameRenderer) drawLeaderboard(dst *image.RGBA, hud *hudStack, st frameState, order []int) {
+	lo := r.opts.Leaderboard
+	if !lo.Enabled || r.course == nil { return }
+
+	lead := order[0]
+	leadD := -1.0
+	if i := st.End[lead]; i >= 0 { leadD = r.course[lead][min(i, len(r.course[lead])-1)] }
+
+	lines := make([]string, 0, len(order))
+	for place, tIdx := range order {
+		name := fmt.Sprintf("#%d", tIdx+1)
+		if tIdx < len(r.opts.Names) && r.opts.Names[tIdx] != "" { name = r.opts.Names[tIdx] }
+		parts := []string{fmt.Sprintf("%d.", place+1), name}
+
+		i := st.End[tIdx]
+		switch {
+		case i < 0 || leadD < 0:
+			parts = append(parts, "—")
+		case place == 0:
+			parts = append(parts, fmtDist(leadD, lo.Units))
+		default:
+			d := r.course[tIdx][min(i, len(r.course[tIdx])-1)]
+			gap := "+" + fmtGapDist(leadD-d, lo.Units)
+			// по времени: сколько назад лидер был там, где сейчас этот гонщик
+			if lo.GapTime && st.HasT {
+				if t, ok := r.timeAtCourse(lead, d); ok {
+					now := st.T
+					if ft, ok := r.finishedAt(tIdx, st); ok { now = ft }
+					gap = "+" + fmtSplit(max(0, now.Sub(t)))
+				}
+			}
+			parts = append(parts, gap)
+		}
+		lines = append(lines, strings.Join(parts, " "))
+	}
+
+	// каждая строка — цветом своего трека
+	idx := make([]int, len(lines))
+	for k := range idx { idx[k] = k }
+	if lo.Corner == cornerBL || lo.Corner == cornerBR {
+		for k := range idx { idx[k] = len(lines) - 1 - k }
+	}
+	for _, k := range idx {
+		ts := r.opts.Text
+		ts.Color = r.opts.Colors[order[k]%len(r.opts.Colors)]
+		w, h := ts.BoxSize(lines[k])
+		p := hud.place(lo.Corner, w, h)
+		tiles.DrawText(dst, p.X, p.Y, lines[k], ts)
+	}
+}
+
+// время финиша, если трек уже финишировал к кадру
+func (r *frameRenderer) finishedAt(tIdx int, st frameState) (time.Time, bool) {
+	pts := r.tracks[tIdx]
+	if len(pts) == 0 || st.End[tIdx] < len(pts)-1 || pts[len(pts)-1].T == nil { return time.Time{}, false }
+	return *pts[len(pts)-1].T, true
+}
+
+// кольцо вокруг маркера лидера
+var leaderRing = color.RGBA{255, 204, 0, 255}
+
+func fmtGapDist(m float64, units string) string {
+	if units == unitsImperial {
+		if m < 1609.344 { return fmt.Sprintf("%.0f ft", m*3.28084) }
+		return fmt.Sprintf("%.2f mi", m/1609.344)
+	}
+	if m < 1000 { return fmt.Sprintf("%.0f м", m) }
+	return fmt.Sprintf("%.2f км", m/1000)
+}
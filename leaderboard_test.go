@@ -0,0 +1,162 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// метров в градусе широты
+const mPerDeg = earthRadiusM * math.Pi / 180
+
+// точка в north/east метрах от 60°N 30°E
+func ptM(north, east float64) PtLL {
+	return PtLL{Lat: 60 + north/mPerDeg, Lon: 30 + east/(mPerDeg*math.Cos(60*math.Pi/180))}
+}
+
+func courseRenderer(tracks ...[]PtLL) *frameRenderer {
+	var all []PtLL
+	r := &frameRenderer{tracks: tracks, dist: make([][]float64, len(tracks))}
+	for i, pts := range tracks {
+		r.dist[i] = cumDist(pts)
+		all = append(all, pts...)
+	}
+	r.bb = bboxLL(all)
+	r.opts.Leaderboard = LeaderboardOpts{Enabled: true}
+	return r
+}
+
+func TestCourseRefTrack(t *testing.T) {
+	in := []string{"a.gpx", "empty.gpx", "c.gpx"}
+	inIdx := []int{0, 2} // empty.gpx пропущен
+	for _, tc := range []struct {
+		n, want int
+		ok      bool
+	}{
+		{1, 0, true},
+		{3, 1, true}, // третий файл в -in — второй загруженный трек
+		{2, 0, false},
+		{0, 0, false},
+		{4, 0, false},
+	} {
+		got, err := courseRefTrack(tc.n, in, inIdx)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("courseRef %d: %d, %v; want %d, ok=%v", tc.n, got, err, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestBuildCourseZigZag(t *testing.T) {
+	// эталон — прямая на север 2 км, точки каждые 5 м
+	var ref, lead []PtLL
+	for i := 0; i <= 400; i++ {
+		d := float64(i) * 5
+		ref = append(ref, ptM(d, 0))
+		// лидер на 20 м впереди, петляет поперёк трассы на ±25 м,
+		// а каждая 7-я точка — выброс GPS на 30 м назад
+		north, east := d+20, 25.0
+		if i%2 == 1 { east = -25 }
+		if i%7 == 3 { north = d - 10 }
+		lead = append(lead, ptM(north, east))
+	}
+	r := courseRenderer(ref, lead)
+	r.buildCourse()
+
+	for tIdx, c := range r.course {
+		for i := 1; i < len(c); i++ {
+			if c[i] < c[i-1] { t.Fatalf("track %d: course goes back at %d: %.1f -> %.1f", tIdx, i, c[i-1], c[i]) }
+		}
+	}
+	if c := r.course[0]; math.Abs(c[0]) > 1 || math.Abs(c[len(c)-1]-2000) > 5 {
+		t.Errorf("reference course %.1f..%.1f, want 0..2000", c[0], c[len(c)-1])
+	}
+	// на последних 20 м лидер упирается в конец трассы — там ничья
+	for i := range ref[:len(ref)-4] {
+		order := r.ranking(frameState{End: []int{i, i}})
+		if order[0] != 1 { t.Fatalf("point %d: places %v, the zig-zagging leader dropped behind", i, order) }
+	}
+}
+
+func TestBuildCourseLoop(t *testing.T) {
+	// кольцо 4×500 м: финиш совпадает со стартом
+	var ring []PtLL
+	corners := [][2]float64{{0, 0}, {500, 0}, {500, 500}, {0, 500}, {0, 0}}
+	for k := 0; k < 4; k++ {
+		a, b := corners[k], corners[k+1]
+		for i := 0; i < 50; i++ {
+			f := float64(i) / 50
+			ring = append(ring, ptM(a[0]+(b[0]-a[0])*f, a[1]+(b[1]-a[1])*f))
+		}
+	}
+	ring = append(ring, ptM(0, 0))
+	// второй гонщик стартует в 3 м от старта и проходит круг целиком
+	rider := []PtLL{ptM(3, 2), ptM(250, 3), ptM(500, 250), ptM(250, 497), ptM(2, 1)}
+	r := courseRenderer(ring, rider)
+	r.buildCourse()
+	c := r.course[1]
+	if c[0] > 10 { t.Errorf("start projected to %.0f m, want the start of the loop", c[0]) }
+	if math.Abs(c[len(c)-1]-2000) > 10 { t.Errorf("finish projected to %.0f m, want ~2000", c[len(c)-1]) }
+}
+
+func TestRanking(t *testing.T) {
+	t0 := time.Date(2025, 5, 31, 10, 0, 0, 0, time.UTC)
+	at := func(s int) *time.Time { tt := t0.Add(time.Duration(s) * time.Second); return &tt }
+	pts := func(ts ...*time.Time) []PtLL {
+		out := make([]PtLL, len(ts))
+		for i := range ts { out[i].T = ts[i] }
+		return out
+	}
+	r := &frameRenderer{
+		tracks: [][]PtLL{
+			pts(at(0), at(10), at(50)),  // финиш в 50 с
+			pts(at(0), at(10), at(40)),  // финиш раньше, но короче по трассе
+			pts(at(0), at(10), at(60)),
+			pts(at(30), at(40), at(70)), // ещё не стартовал
+		},
+		course: [][]float64{{0, 100, 1000}, {0, 120, 990}, {0, 150, 900}, {0, 10, 1000}},
+	}
+	for _, tc := range []struct {
+		name string
+		end  []int
+		want []int
+	}{
+		{"on course", []int{1, 1, 1, -1}, []int{2, 1, 0, 3}},
+		{"finishers by time", []int{2, 2, 1, 0}, []int{1, 0, 2, 3}},
+		{"finisher ahead of riders", []int{2, 1, 1, 1}, []int{0, 2, 1, 3}},
+	} {
+		got := r.ranking(frameState{End: tc.end})
+		for i := range got {
+			if got[i] != tc.want[i] { t.Errorf("%s: %v, want %v", tc.name, got, tc.want); break }
+		}
+	}
+}
+
+func TestTimeAtCourse(t *testing.T) {
+	t0 := time.Date(2025, 5, 31, 10, 0, 0, 0, time.UTC)
+	at := func(s int) *time.Time { tt := t0.Add(time.Duration(s) * time.Second); return &tt }
+	r := &frameRenderer{
+		tracks: [][]PtLL{
+			{{T: at(0)}, {T: at(10)}, {T: at(20)}, {T: at(40)}},
+			{{}, {}},
+		},
+		course: [][]float64{{0, 100, 100, 300}, {0, 50}},
+	}
+	for _, tc := range []struct {
+		d    float64
+		want int
+		ok   bool
+	}{
+		{0, 0, true},
+		{50, 5, true},
+		{100, 10, true}, // стоянка: первый момент в точке
+		{200, 30, true},
+		{300, 40, true},
+		{301, 0, false},
+	} {
+		got, ok := r.timeAtCourse(0, tc.d)
+		if ok != tc.ok || (ok && !got.Equal(*at(tc.want))) {
+			t.Errorf("d=%g: %v, %v; want +%ds, %v", tc.d, got, ok, tc.want, tc.ok)
+		}
+	}
+	if _, ok := r.timeAtCourse(1, 20); ok { t.Error("track without time: ok") }
+}
@@ -7,10 +7,12 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	"io"
 	"log"
 	"math"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -26,6 +28,7 @@ func (m *multiIn) Set(s string) error { *m = append(*m, s); return nil }
 
 var (
 	inMany        multiIn
+	tileLayers    multiIn
 	outGIF        = flag.String("out", "synced.gif", "куда сохранить GIF")
 	size          = flag.Int("size", 512, "размер кадра (квадрат)")
 	fps           = flag.Float64("fps", 20.0, "кадров в секунду")
@@ -36,16 +39,115 @@ var (
 	lineWidth     = flag.Int("lineWidth", 4, "толщина линии трека в пикселях")
 	pprofAddr     = flag.String("pprof", "", "включить pprof на адресе (например 127.0.0.1:6060), пусто = выключено")
 
+	// обводка и тень для читаемости на спутниковых подложках
+	outlineColorsStr = flag.String("outlineColors", "#000000", "цвета обводки треков, через запятую (hex), по кругу как lineColors")
+	outlineWidth     = flag.Int("outlineWidth", 0, "ширина обводки с каждой стороны линии, px (0 = без обводки)")
+	shadow           = flag.Bool("shadow", false, "мягкая тень под треками")
+	shadowHex        = flag.String("shadowColor", "#A0000000", "цвет тени (hex, #AARRGGBB)")
+	shadowOffset     = flag.Int("shadowOffset", 2, "сдвиг тени вправо-вниз, px")
+	shadowBlur       = flag.Int("shadowBlur", 3, "радиус размытия тени, px")
+
+	// маркер текущей позиции и подписи
+	marker     = flag.String("marker", "none", "маркер текущей позиции: none | circle | arrow | avatar")
+	markerSize = flag.Int("markerSize", 14, "размер маркера, px")
+	avatarsStr = flag.String("avatars", "", "PNG/JPEG аватарки для marker=avatar, через запятую (по трекам)")
+	labelsStr  = flag.String("labels", "", "подписи треков через запятую (по умолчанию — <name> из GPX)")
+	headLabels = flag.Bool("headLabels", false, "показывать подпись рядом с маркером")
+
+	// стиль анимации
+	style      = flag.String("style", "full", "стиль анимации: full | comet | ghost | heatmap | heatmap-static")
+	tailDur    = flag.Duration("tail", 5*time.Minute, "длина хвоста comet/ghost по времени")
+	tailKm     = flag.Float64("tailKm", 0, "длина хвоста comet/ghost по дистанции, км (> 0 — вместо -tail)")
+	ghostAlpha = flag.Float64("ghostAlpha", 0.25, "непрозрачность полного маршрута в стиле ghost (0..1)")
+	heatCmap   = flag.String("heatColormap", "hot", "палитра теплокарты: hot | viridis")
+	heatRadius = flag.Int("heatRadius", 3, "радиус размытия теплокарты, px")
+
+	// шрифт для подписей и оверлеев (по умолчанию встроенный Go Regular с кириллицей)
+	fontPath       = flag.String("font", "", "TTF/OTF шрифт для текста, пусто = встроенный")
+	fontSize       = flag.Float64("fontSize", 13, "размер шрифта подписей и оверлеев, pt")
+	attribSize     = flag.Float64("attribSize", 10, "размер шрифта атрибуции карты, pt")
+	textHex        = flag.String("textColor", "#ffffff", "цвет текста оверлеев (hex)")
+	textOutlineHex = flag.String("textOutline", "#C8000000", "цвет обводки текста (hex), пусто = без обводки")
+	textOutlineW   = flag.Int("textOutlineWidth", 1, "толщина обводки текста, px")
+	textPanelHex   = flag.String("textPanel", "#78000000", "цвет плашки под текстом (hex), пусто = без плашки")
+
+	// часы: время кадра, дата и прошедшее время
+	clock        = flag.Bool("clock", false, "показывать часы времени кадра (только для GPX со временем)")
+	tz           = flag.String("tz", "Local", "часовой пояс часов (IANA, например Europe/Moscow)")
+	clockPos     = flag.String("clockPos", "tl", "угол часов: tl | tr | bl | br")
+	clockFormat  = flag.String("clockFormat", "15:04:05", "формат времени (layout Go)")
+	dateFormat   = flag.String("dateFormat", "02.01.2006", "формат даты (layout Go), пусто = без даты")
+	clockElapsed = flag.Bool("clockElapsed", true, "показывать время с начала")
+
+	// панель статистики по трекам
+	stats       = flag.Bool("stats", false, "панель статистики по трекам: дистанция, скорость, высота, пульс")
+	statsPos    = flag.String("statsPos", "br", "угол панели статистики: tl | tr | bl | br")
+	units       = flag.String("units", "metric", "единицы: metric | imperial")
+	pace        = flag.Bool("pace", false, "показывать темп (мин/км) вместо скорости")
+	speedWindow = flag.Duration("speedWindow", 30*time.Second, "окно сглаживания скорости")
+
+	// профиль высот
+	profile       = flag.Bool("profile", false, "полоса профиля высот внизу кадра с курсором")
+	profileHeight = flag.Int("profileHeight", 80, "высота полосы профиля, px")
+	profileX      = flag.String("profileX", "dist", "ось X профиля: dist | time")
+
+	// масштабная линейка и стрелка севера (рисуются в подложку)
+	scaleBar   = flag.Bool("scaleBar", false, "масштабная линейка в левом нижнем углу")
+	northArrow = flag.Bool("northArrow", false, "стрелка севера в правом верхнем углу")
+
+	// фильтры подложки
+	mapFilterStr = flag.String("mapFilter", "", "фильтры карты через запятую: gray, desat=0.5, dim=0.4, bright=0.2, contrast=1.3, tint=#40ff8800, blur=1.5")
+
+	// врезка-обзор
+	minimap     = flag.Bool("minimap", false, "врезка с обзором всего маршрута и рамкой текущего вида (с -camera follow|zoom-out)")
+	minimapPos  = flag.String("minimapPos", "tl", "угол врезки: tl | tr | bl | br")
+	minimapSize = flag.Int("minimapSize", 120, "сторона врезки, px")
+
+	// камера
+	camera       = flag.String("camera", "fixed", "камера: fixed | follow (за лидером) | zoom-out (от старта к маршруту)")
+	cameraZoom   = flag.Float64("cameraZoom", 3, "во сколько раз камера ближе полного вида")
+	cameraTarget = flag.String("cameraTarget", "leader", "за кем следит follow: leader | centroid")
+	cameraSmooth = flag.Float64("cameraSmooth", 0.15, "плавность панорамирования follow (0..1, меньше — плавнее)")
+
+	// отметки старта, финиша и сплитов
+	startFinish = flag.Bool("startFinish", false, "отмечать старт и финиш каждого трека")
+	splitEvery  = flag.Float64("splitEvery", 0, "отметки сплитов каждые N км (миль при -units imperial), 0 — выкл")
+
+	// таблица гонки
+	leaderboard    = flag.Bool("leaderboard", false, "таблица гонки: места по дистанции вдоль трассы и отставание от лидера")
+	leaderboardPos = flag.String("leaderboardPos", "tr", "угол таблицы гонки: tl | tr | bl | br")
+	courseRef      = flag.Int("courseRef", 1, "номер трека-эталона трассы (по порядку -in, с 1)")
+	gapMode        = flag.String("gap", "time", "отставание от лидера: time | dist")
+
+	// заставки
+	title     = flag.String("title", "", "заголовок заставки (по умолчанию <metadata><name> из GPX)")
+	intro     = flag.Duration("intro", 0, "длительность вступительной заставки: заголовок, дата, участники (0 — без неё)")
+	outro     = flag.Duration("outro", 0, "длительность итогового кадра: весь маршрут и сводка по трекам (0 — без него)")
+	titleSize = flag.Float64("titleSize", 24, "размер шрифта заголовка заставки, pt")
+
+	// ключи для ${VAR} в URL и заголовках (переменные окружения важнее)
+	secretsFile = flag.String("secrets", "", "файл KEY=VALUE с ключами для ${VAR} в -staticURL, -tilesURL и пресетах")
+
 	// статичная картинка (Mapbox/MapTiler и др.)
 	staticURL = flag.String("staticURL", "", "шаблон URL статической карты с плейсхолдерами {minLon},{minLat},{maxLon},{maxLat},{w},{h}")
 
 	// тайловые карты через модуль tiles
-	tilesPreset = flag.String("tilesPreset", "", "opentopomap | esri-satellite | maptiler-satellite | stamen-terrain-bg")
-	tilesURL    = flag.String("tilesURL", "", "custom tile URL template with {z}/{x}/{y}")
-	tileCache   = flag.String("tileCache", ".tile-cache", "tile cache dir")
-	tilesRPS    = flag.Float64("tilesRPS", 1.0, "tile requests per second (OpenTopoMap≈1)")
-	tilesBurst  = flag.Int("tilesBurst", 1, "tile burst")
-	tilesTO     = flag.Duration("tilesTimeout", 8*time.Second, "tile HTTP timeout")
+	tilesPreset  = flag.String("tilesPreset", "", "opentopomap | esri-satellite | maptiler-satellite | stamen-terrain-bg")
+	tilesURL     = flag.String("tilesURL", "", "custom tile URL template: {z} {x} {y} {-y} {q} {s} {r}")
+	tileCache    = flag.String("tileCache", ".tile-cache", "tile cache dir")
+	tileTTL      = flag.Duration("tileTTL", 30*24*time.Hour, "revalidate cached tiles older than this unless the server sent max-age (0 = never)")
+	tileCacheMax = flag.String("tileCacheMax", "1G", "max tile cache size, LRU eviction beyond it (e.g. 500M, 2G; 0 = unlimited)")
+	tilesRPS     = flag.Float64("tilesRPS", 1.0, "tile requests per second (OpenTopoMap≈1)")
+	tilesBurst   = flag.Int("tilesBurst", 1, "tile burst")
+	tilesWorkers = flag.Int("tilesWorkers", tiles.DefaultWorkers, "concurrent tile requests (rate still limited by -tilesRPS)")
+	tilesTO      = flag.Duration("tilesTimeout", 8*time.Second, "tile HTTP timeout")
+	tilesSubs    = flag.String("tilesSubdomains", "", "subdomains for {s}, comma separated (default a,b,c)")
+	tilesRetina  = flag.Bool("tilesRetina", false, "request @2x tiles via {r}")
+	tilesMBTiles = flag.String("tilesMBTiles", "", "local .mbtiles package instead of HTTP tiles (zoom range and attribution from metadata)")
+	tilesPMTiles = flag.String("tilesPMTiles", "", "PMTiles v3 archive: local path or http(s) URL read with range requests")
+	tilesDir     = flag.String("tilesDir", "", "local tile directory template, e.g. /data/osm/{z}/{x}/{y}.png")
+	tilesStrict  = flag.Bool("tilesStrict", false, "fail on the first missing base tile instead of overzooming a parent or drawing a placeholder (overlay layers stay transparent)")
+	offline      = flag.Bool("offline", false, "never touch the network: HTTP tiles only from -tileCache, a miss is an error")
 
 	// подгонка карты под квадратный кадр
 	tileFit = flag.String("tileFit", "contain", "fit mode for tile background: contain | cover")
@@ -55,6 +157,13 @@ var (
 
 func main() {
 	flag.Var(&inMany, "in", "путь к GPX (можно указывать много раз)")
+	flag.Var(&tileLayers, "tilesLayer", "overlay layer over the tile base, repeatable: SOURCE[,opacity=0.6][,blend=multiply]; SOURCE is a preset, URL template, .mbtiles/.pmtiles or {z}/{x}/{y} dir")
+	if len(os.Args) > 1 && os.Args[1] == "tiles" {
+		if err := tilesCmd(os.Args[2:]); err != nil {
+			log.Fatalf("❌ Ошибка: %v", err)
+		}
+		return
+	}
 	flag.Parse()
 
 	if *pprofAddr != "" {
@@ -99,17 +208,22 @@ func run(
 
 	// загрузка GPX
 	var tracks [][]PtLL
+	var names, metaNames []string
+	var inIdx []int // номер файла в -in для каждого трека: пустые GPX пропускаются
 	totalPts := 0
-	for _, p := range inPaths {
-		pts, err := ParseGPXFile(p)
+	for k, p := range inPaths {
+		t, err := ParseGPX(p)
 		if err != nil {
 			return fmt.Errorf("parse gpx %s: %w", p, err)
 		}
-		if len(pts) == 0 {
+		if len(t.Pts) == 0 {
 			continue
 		}
-		tracks = append(tracks, pts)
-		totalPts += len(pts)
+		tracks = append(tracks, t.Pts)
+		inIdx = append(inIdx, k)
+		names = append(names, t.Name)
+		metaNames = append(metaNames, t.MetaName)
+		totalPts += len(t.Pts)
 	}
 	if len(tracks) == 0 {
 		return errors.New("нет точек во входных GPX")
@@ -145,6 +259,179 @@ func run(
 		return errors.New("lineColors пуст — укажите хотя бы один цвет")
 	}
 
+	opts := RenderOpts{
+		Colors:       trackColors,
+		Width:        *lineWidth,
+		OutlineWidth: *outlineWidth,
+		Shadow:       *shadow,
+		ShadowOffset: *shadowOffset,
+		ShadowBlur:   *shadowBlur,
+	}
+	if opts.OutlineWidth > 0 {
+		opts.OutlineColors, err = ParseHexColors(*outlineColorsStr)
+		if err != nil {
+			return fmt.Errorf("outlineColors: %w", err)
+		}
+	}
+	if opts.Shadow {
+		opts.ShadowColor, err = ParseHexColor(*shadowHex)
+		if err != nil {
+			return fmt.Errorf("shadowColor: %w", err)
+		}
+	}
+
+	switch *style {
+	case styleFull, styleComet, styleGhost, styleHeatmap, styleHeatmapStatic:
+		opts.Style = *style
+	default:
+		return fmt.Errorf("неизвестный style: %q (full|comet|ghost|heatmap|heatmap-static)", *style)
+	}
+	if opts.Heatmap.Colormap, err = parseColormap(*heatCmap); err != nil {
+		return err
+	}
+	opts.Heatmap.Radius = *heatRadius
+	opts.TailDur = *tailDur
+	opts.TailM = *tailKm * 1000
+	if *ghostAlpha < 0 || *ghostAlpha > 1 {
+		return fmt.Errorf("ghostAlpha должен быть в диапазоне [0..1], сейчас: %g", *ghostAlpha)
+	}
+	opts.GhostAlpha = *ghostAlpha
+
+	if opts.Text, err = loadTextStyle(*fontSize); err != nil {
+		return err
+	}
+	attribText, err := loadTextStyle(*attribSize)
+	if err != nil {
+		return err
+	}
+
+	if *clock {
+		loc, err := time.LoadLocation(*tz)
+		if err != nil {
+			return fmt.Errorf("tz: %w", err)
+		}
+		corner, err := parseCorner(*clockPos)
+		if err != nil {
+			return fmt.Errorf("clockPos: %w", err)
+		}
+		opts.Clock = ClockOpts{
+			Enabled:    true,
+			Loc:        loc,
+			Corner:     corner,
+			TimeFormat: *clockFormat,
+			DateFormat: *dateFormat,
+			Elapsed:    *clockElapsed,
+		}
+	}
+
+	opts.Names = names
+	u, err := parseUnits(*units)
+	if err != nil {
+		return err
+	}
+	if *stats {
+		corner, err := parseCorner(*statsPos)
+		if err != nil {
+			return fmt.Errorf("statsPos: %w", err)
+		}
+		opts.Stats = StatsOpts{Enabled: true, Corner: corner, Units: u, Pace: *pace, Window: *speedWindow}
+	}
+
+	if *profile {
+		if *profileX != "dist" && *profileX != "time" {
+			return fmt.Errorf("неизвестный profileX: %q (dist|time)", *profileX)
+		}
+		if *profileHeight <= 0 || *profileHeight > px/2 {
+			return fmt.Errorf("profileHeight должен быть в диапазоне [1..%d], сейчас: %d", px/2, *profileHeight)
+		}
+		opts.Profile = ProfileOpts{Enabled: true, Height: *profileHeight, ByTime: *profileX == "time"}
+	}
+
+	if *splitEvery < 0 {
+		return errors.New("splitEvery должен быть >= 0")
+	}
+	opts.Splits = SplitOpts{StartFinish: *startFinish, Every: *splitEvery * 1000, Units: u}
+	if u == unitsImperial {
+		opts.Splits.Every = *splitEvery * 1609.344
+	}
+
+	if *leaderboard {
+		corner, err := parseCorner(*leaderboardPos)
+		if err != nil {
+			return fmt.Errorf("leaderboardPos: %w", err)
+		}
+		ref, err := courseRefTrack(*courseRef, inPaths, inIdx)
+		if err != nil {
+			return err
+		}
+		if *gapMode != "time" && *gapMode != "dist" {
+			return fmt.Errorf("неизвестный gap: %q (time|dist)", *gapMode)
+		}
+		opts.Leaderboard = LeaderboardOpts{Enabled: true, Corner: corner, Ref: ref, GapTime: *gapMode == "time", Units: u}
+	}
+
+	if *minimap {
+		corner, err := parseCorner(*minimapPos)
+		if err != nil {
+			return fmt.Errorf("minimapPos: %w", err)
+		}
+		if *minimapSize < 32 || *minimapSize > px/2 {
+			return fmt.Errorf("minimapSize должен быть в диапазоне [32..%d]", px/2)
+		}
+		opts.Minimap = MinimapOpts{Enabled: true, Corner: corner, Size: *minimapSize}
+	}
+
+	if *intro < 0 || *outro < 0 {
+		return errors.New("intro и outro должны быть >= 0")
+	}
+	if *intro > 0 || *outro > 0 {
+		loc, err := time.LoadLocation(*tz)
+		if err != nil {
+			return fmt.Errorf("tz: %w", err)
+		}
+		opts.Cards = CardOpts{Title: cardTitle(*title, metaNames), Loc: loc, DateFormat: *dateFormat, Units: u, Intro: *intro, Outro: *outro}
+		if opts.Cards.TitleText, err = loadTextStyle(*titleSize); err != nil {
+			return err
+		}
+		opts.Cards.TitleText.Panel = nil
+	}
+
+	camMode, camTarget, err := parseCamera(*camera, *cameraTarget)
+	if err != nil {
+		return err
+	}
+	opts.Camera = CameraOpts{Mode: camMode, Zoom: *cameraZoom, Target: camTarget, Smooth: *cameraSmooth}
+	if opts.Minimap.Enabled && camMode == cameraFixed {
+		// неподвижная камера и так показывает весь маршрут: врезка повторила бы кадр
+		log.Printf("⚠️ -minimap работает только с подвижной камерой (-camera follow|zoom-out) — врезка отключена")
+		opts.Minimap.Enabled = false
+	}
+	if camMode != cameraFixed && (opts.Style == styleHeatmap || opts.Style == styleHeatmapStatic) {
+		return errors.New("теплокарта строится по всему маршруту — только с -camera fixed")
+	}
+
+	if opts.Marker, err = parseMarker(*marker); err != nil {
+		return err
+	}
+	if *markerSize <= 0 {
+		return fmt.Errorf("markerSize должен быть > 0, сейчас: %d", *markerSize)
+	}
+	opts.MarkerSize = *markerSize
+	if opts.Marker == markerAvatar {
+		if opts.Avatars, err = loadAvatars(*avatarsStr, opts.MarkerSize); err != nil {
+			return err
+		}
+	}
+	if *headLabels || *labelsStr != "" {
+		opts.Labels = names
+		if *labelsStr != "" {
+			opts.Labels = strings.Split(*labelsStr, ",")
+			for i := range opts.Labels {
+				opts.Labels[i] = strings.TrimSpace(opts.Labels[i])
+			}
+		}
+	}
+
 	// общий bbox
 	bb := boundsLL{minLat: math.MaxFloat64, minLon: math.MaxFloat64, maxLat: -math.MaxFloat64, maxLon: -math.MaxFloat64}
 	for _, pts := range tracks {
@@ -164,59 +451,212 @@ func run(
 	maxLat := bb.maxLat + padLat
 
 	// фон
+	fullView := boundsLL{minLon: minLon, minLat: minLat, maxLon: maxLon, maxLat: maxLat}
+	filters, err := parseMapFilters(*mapFilterStr)
+	if err != nil {
+		return err
+	}
+	// фильтры — один раз на готовую подложку, до атрибуции и оверлеев
+	filterBase := func(base image.Image) image.Image {
+		if base == nil || len(filters) == 0 {
+			return base
+		}
+		canvas := baseCanvas(base, px, px, bg)
+		applyMapFilters(canvas, filters)
+		return canvas
+	}
 	var baseImg image.Image
+	var tileBase func(ctx context.Context, view boundsLL) (image.Image, error) // подложка из тайлов под любую область
+	var degraded []*tiles.Fallback                                              // подмены недостающих тайлов (слои; база — без -tilesStrict)
+	var attribution string                                                      // подпись источника карты, рисуется в decorate
+
+	secrets, err := tiles.LoadSecrets(*secretsFile)
+	if err != nil {
+		return fmt.Errorf("secrets: %w", err)
+	}
+	if len(tileLayers) > 0 && *tilesMBTiles == "" && *tilesPMTiles == "" && *tilesDir == "" && *tilesPreset == "" && tilesURLArg == "" {
+		return errors.New("-tilesLayer накладывается на тайловую подложку: задайте базу (-tilesPreset, -tilesURL, -tilesMBTiles, -tilesPMTiles или -tilesDir)")
+	}
 
 	switch {
 	case staticURLArg != "":
-		url := expandStaticURL(staticURLArg, boundsLL{
-			minLon: minLon, minLat: minLat,
-			maxLon: maxLon, maxLat: maxLat,
-		}, px, px)
+		if *offline {
+			return errors.New("-offline: статической карте (-staticURL) нужна сеть, используйте -tilesDir, -tilesMBTiles или кэш тайлов")
+		}
+		tpl, err := secrets.ExpandVars(staticURLArg)
+		if err != nil {
+			return fmt.Errorf("staticURL: %w", err)
+		}
+		url := expandStaticURL(tpl, fullView, px, px)
 		baseImg, err = fetchStaticMap(ctx, url)
 		if err != nil {
 			return fmt.Errorf("fetch map: %w", err)
 		}
-		baseImg = fitBaseToCanvas(baseImg, px, px, *tileFit, bg)
+		baseImg = filterBase(fitBaseToCanvas(baseImg, px, px, *tileFit, bg))
 
-	case *tilesPreset != "" || tilesURLArg != "":
-		fetcher, ferr := tiles.NewFetcher(*tileCache, *tilesRPS, *tilesBurst, *tilesTO)
-		if ferr != nil {
-			return fmt.Errorf("tiles fetcher: %w", ferr)
+	case *tilesMBTiles != "" || *tilesPMTiles != "" || *tilesDir != "" || *tilesPreset != "" || tilesURLArg != "":
+		var src tiles.Source
+		var preset tiles.Preset
+		// один Fetcher на базу и все слои: общие лимит запросов, пауза после 429 и учёт размера кэша
+		fetcher, err := newFetcher()
+		if err != nil {
+			return err
+		}
+		if *tilesMBTiles != "" {
+			mb, err := tiles.OpenMBTiles(*tilesMBTiles)
+			if err != nil {
+				return err
+			}
+			defer mb.Close()
+			if preset, err = mb.Preset(ctx); err != nil {
+				return err
+			}
+			src = mb
+		} else if *tilesPMTiles != "" {
+			pm, err := openPMTiles(ctx, fetcher, secrets, *tilesPMTiles)
+			if err != nil {
+				return err
+			}
+			defer pm.Close()
+			if preset, err = pm.Preset(ctx); err != nil {
+				return err
+			}
+			src = pm
+		} else if *tilesDir != "" {
+			ds, err := tiles.NewDirSource(*tilesDir)
+			if err != nil {
+				return err
+			}
+			src, preset = ds, ds.Preset
+		} else if src, preset, err = httpTileSource(fetcher, secrets, *tilesPreset, tilesURLArg); err != nil {
+			return err
 		}
 
-		var preset tiles.Preset
-		if *tilesPreset != "" {
-			p, ok := tiles.Presets[*tilesPreset]
-			if !ok {
-				return fmt.Errorf("unknown tilesPreset: %s", *tilesPreset)
+		// слои поверх базы: рельеф, подписи и т.п.; зум — как у базы или ближайший из диапазона слоя
+		layers := []tiles.Layer{{Src: src, Preset: preset, Opacity: 1}}
+		for _, spec := range tileLayers {
+			l, closer, err := openTileLayer(ctx, fetcher, secrets, spec)
+			if err != nil {
+				return fmt.Errorf("tilesLayer %q: %w", spec, err)
+			}
+			if closer != nil { defer closer.Close() }
+			layers = append(layers, l)
+		}
+		// -tilesStrict касается только базы: дыра в слое просто прозрачна
+		for i := range layers {
+			if i == 0 && *tilesStrict { continue }
+			fb := tiles.NewFallback(layers[i].Src)
+			// заглушка на прозрачном слое не должна закрывать базу
+			if i > 0 { fb.Placeholder = color.Transparent }
+			layers[i].Src = fb
+			degraded = append(degraded, fb)
+		}
+		preset.Attribution = layerAttribution(layers)
+		attribution = preset.Attribution
+		if opts.Camera.Mode != cameraFixed {
+			// камера строит мозаику на каждый кадр: декодированные тайлы держим в памяти,
+			// с запасом на несколько кадров (соседние кадры видят почти те же тайлы)
+			perFrame := (px/tiles.TileSize + 2) * (px/tiles.TileSize + 2)
+			for i := range layers {
+				layers[i].Src = tiles.NewImageCache(layers[i].Src, max(64, 4*perFrame))
+			}
+		}
+		if preset.Attribution != "" {
+			_, ah := attribText.BoxSize(preset.Attribution)
+			opts.Profile.Bottom = ah + 6
+		}
+
+		tileBase = func(ctx context.Context, view boundsLL) (image.Image, error) {
+			bgRGBA, _, merr := tiles.BuildLayeredMosaic(
+				ctx, layers,
+				view.minLon, view.minLat, view.maxLon, view.maxLat,
+				px, px,
+			)
+			if merr != nil {
+				return nil, fmt.Errorf("build mosaic: %w", merr)
+			}
+			return baseCanvas(filterBase(fitBaseToCanvas(bgRGBA, px, px, *tileFit, bg)), px, px, bg), nil
+		}
+
+		if opts.Minimap.Enabled {
+			// своя мозаика мелкого зума под размер врезки
+			mm, _, merr := tiles.BuildLayeredMosaic(
+				ctx, layers,
+				fullView.minLon, fullView.minLat, fullView.maxLon, fullView.maxLat,
+				opts.Minimap.Size, opts.Minimap.Size,
+			)
+			if merr != nil {
+				return fmt.Errorf("minimap mosaic: %w", merr)
+			}
+			applyMapFilters(mm, filters)
+			opts.Minimap.Base = mm
+		}
+
+		if opts.Camera.Mode == cameraFixed {
+			if baseImg, err = tileBase(ctx, fullView); err != nil {
+				return err
 			}
-			preset = p
 		} else {
-			preset = tiles.Preset{
-				Name:        "custom",
-				URLTmpl:     tilesURLArg,
-				Attribution: "© data providers",
-				MinZoom:     0,
-				MaxZoom:     22,
+			// камера смотрит ближе — заранее тянем тайлы крупного зума только по её пути
+			opts.Camera.Prefetch = func(ctx context.Context, views []boundsLL) error {
+				boxes := make([][4]float64, len(views))
+				for i, v := range views {
+					boxes[i] = [4]float64{v.minLon, v.minLat, v.maxLon, v.maxLat}
+				}
+				return tiles.PrefetchLayers(ctx, layers, boxes, px, px)
 			}
 		}
+	}
+
+	// миникарта — из подложки без подписей: decorate ниже рисует на копии
+	if opts.Minimap.Enabled && opts.Minimap.Base == nil && baseImg != nil {
+		opts.Minimap.Base = baseImg
+	}
 
-		bgRGBA, _, merr := tiles.BuildMosaic(
-			ctx, fetcher, preset,
-			minLon, minLat, maxLon, maxLat,
-			px, px,
-		)
-		if merr != nil {
-			return fmt.Errorf("build mosaic: %w", merr)
+	// атрибуция, масштабная линейка и стрелка севера — в подложку;
+	// рисуем на копии: base может быть общей подложкой, из которой режутся кадры
+	// камеры и берётся миникарта
+	decorate := func(base image.Image, view boundsLL) image.Image {
+		if !*scaleBar && !*northArrow && attribution == "" {
+			return base
+		}
+		canvas := copyCanvas(base, px, px, bg)
+		tiles.DrawAttribution(canvas, attribution, attribText)
+		if *scaleBar {
+			// метров на пиксель по горизонтали на широте центра кадра
+			lat := (view.minLat + view.maxLat) / 2
+			mpp := haversine(PtLL{Lat: lat, Lon: view.minLon}, PtLL{Lat: lat, Lon: view.maxLon}) / float64(px-1)
+			bottom := 0
+			if opts.Profile.Enabled {
+				bottom = opts.Profile.Bottom + max(opts.Profile.Height, 40) + 6
+			}
+			tiles.DrawScaleBar(canvas, mpp, u == unitsImperial, bottom, opts.Text)
+		}
+		if *northArrow {
+			tiles.DrawNorthArrow(canvas, opts.Text)
+		}
+		return canvas
+	}
+
+	if opts.Camera.Mode != cameraFixed {
+		fullBase := baseImg
+		opts.Camera.Base = func(ctx context.Context, view boundsLL) (image.Image, error) {
+			if tileBase == nil {
+				return decorate(cropView(fullBase, fullView, view, px), view), nil
+			}
+			b, err := tileBase(ctx, view)
+			if err != nil {
+				return nil, err
+			}
+			return decorate(b, view), nil
 		}
-		tiles.DrawAttribution(bgRGBA, preset.Attribution)
-		baseImg = fitBaseToCanvas(bgRGBA, px, px, *tileFit, bg)
 	}
+	baseImg = decorate(baseImg, fullView)
 
 	// кадры
 	frames, delays, err := BuildFramesMulti(
 		ctx, tracks, px, totalFrames, margin,
-		bg, trackColors, *lineWidth, baseImg,
+		bg, opts, baseImg,
 	)
 	if err != nil {
 		return fmt.Errorf("build frames: %w", err)
@@ -235,6 +675,14 @@ func run(
 		}
 		_ = os.Remove(tmpOut)
 	}
+	over, ph := 0, 0
+	for _, fb := range degraded {
+		o, p := fb.Degraded()
+		over, ph = over+o, ph+p
+	}
+	if over+ph > 0 {
+		log.Printf("⚠️ Тайлов не хватило: %d (увеличены из родительского зума: %d, заглушки: %d); -tilesStrict — падать на недостающих тайлах базы", over+ph, over, ph)
+	}
 	return nil
 }
 
@@ -283,6 +731,169 @@ func copyFile(src, dst string) error {
 	return out.Sync()
 }
 
+// ---- helper: источник тайлов ----
+
+// общий Fetcher из флагов -tiles*/-tileCache*
+func newFetcher() (*tiles.Fetcher, error) {
+	maxBytes, err := parseSize(*tileCacheMax)
+	if err != nil {
+		return nil, fmt.Errorf("tileCacheMax: %w", err)
+	}
+	fetcher, err := tiles.NewFetcher(*tileCache, *tilesRPS, *tilesBurst, *tilesTO)
+	if err != nil {
+		return nil, fmt.Errorf("tiles fetcher: %w", err)
+	}
+	fetcher.Workers = *tilesWorkers
+	fetcher.Offline = *offline
+	fetcher.TTL = *tileTTL
+	fetcher.MaxCacheBytes = maxBytes
+	return fetcher, nil
+}
+
+// HTTP-источник тайлов: пресет или свой шаблон, ключи из окружения/-secrets
+func httpTileSource(fetcher *tiles.Fetcher, secrets tiles.Secrets, presetName, tilesURLArg string) (tiles.Source, tiles.Preset, error) {
+	var err error
+	var preset tiles.Preset
+	if presetName != "" {
+		p, ok := tiles.Presets[presetName]
+		if !ok {
+			return nil, preset, fmt.Errorf("unknown tilesPreset: %s", presetName)
+		}
+		preset = p
+	} else {
+		preset = tiles.Preset{
+			Name:        "custom",
+			URLTmpl:     tilesURLArg,
+			Attribution: "© data providers",
+			MinZoom:     0,
+			MaxZoom:     22,
+		}
+	}
+	if *tilesSubs != "" {
+		preset.Subdomains = strings.Split(*tilesSubs, ",")
+		for i := range preset.Subdomains {
+			preset.Subdomains[i] = strings.TrimSpace(preset.Subdomains[i])
+		}
+	}
+	preset.Retina = *tilesRetina
+	// ключи подставляем до первого запроса: без них сервер ответит ошибкой на каждый тайл
+	if preset, err = preset.Expand(secrets); err != nil {
+		return nil, preset, err
+	}
+	if err := preset.Validate(); err != nil {
+		return nil, preset, err
+	}
+	return &tiles.HTTPSource{F: fetcher, Preset: preset}, preset, nil
+}
+
+// PMTiles-архив: локальный файл или URL (диапазоны через общий кэширующий Fetcher)
+func openPMTiles(ctx context.Context, fetcher *tiles.Fetcher, secrets tiles.Secrets, arg string) (*tiles.PMTiles, error) {
+	if !strings.HasPrefix(arg, "http://") && !strings.HasPrefix(arg, "https://") {
+		return tiles.OpenPMTiles(ctx, arg)
+	}
+	u, err := secrets.ExpandVars(arg)
+	if err != nil {
+		return nil, fmt.Errorf("tilesPMTiles: %w", err)
+	}
+	return tiles.OpenPMTilesURL(ctx, fetcher, u, nil)
+}
+
+// слой из -tilesLayer: "ИСТОЧНИК[,opacity=0.6][,blend=multiply]"; опции снимаются с конца,
+// и только opacity/blend: прочие "k=v" после запятой — часть URL источника (?style=a,b=2)
+func openTileLayer(ctx context.Context, fetcher *tiles.Fetcher, secrets tiles.Secrets, spec string) (tiles.Layer, io.Closer, error) {
+	l := tiles.Layer{Opacity: 1}
+	parts := strings.Split(spec, ",")
+	n := len(parts) // parts[:n] — источник
+options:
+	for ; n > 1; n-- {
+		k, v, _ := strings.Cut(strings.TrimSpace(parts[n-1]), "=")
+		switch strings.ToLower(strings.TrimSpace(k)) {
+		case "opacity":
+			op, err := strconv.ParseFloat(v, 64)
+			if err != nil || op < 0 || op > 1 { return l, nil, fmt.Errorf("opacity %q: нужно число 0..1", v) }
+			l.Opacity = op
+		case "blend":
+			b, err := tiles.ParseBlend(v)
+			if err != nil { return l, nil, err }
+			l.Blend = b
+		default:
+			break options
+		}
+	}
+	srcSpec := strings.TrimSpace(strings.Join(parts[:n], ","))
+	path, _, _ := strings.Cut(srcSpec, "?")
+	isURL := strings.HasPrefix(srcSpec, "http://") || strings.HasPrefix(srcSpec, "https://")
+
+	switch {
+	case strings.HasSuffix(path, ".mbtiles"):
+		mb, err := tiles.OpenMBTiles(srcSpec)
+		if err != nil { return l, nil, err }
+		if l.Preset, err = mb.Preset(ctx); err != nil { mb.Close(); return l, nil, err }
+		l.Src = mb
+		return l, mb, nil
+	case strings.HasSuffix(path, ".pmtiles"):
+		pm, err := openPMTiles(ctx, fetcher, secrets, srcSpec)
+		if err != nil { return l, nil, err }
+		if l.Preset, err = pm.Preset(ctx); err != nil { pm.Close(); return l, nil, err }
+		l.Src = pm
+		return l, pm, nil
+	case isURL:
+		src, p, err := httpTileSource(fetcher, secrets, "", srcSpec)
+		l.Src, l.Preset = src, p
+		return l, nil, err
+	case tiles.Presets[srcSpec].URLTmpl != "":
+		src, p, err := httpTileSource(fetcher, secrets, srcSpec, "")
+		l.Src, l.Preset = src, p
+		return l, nil, err
+	case strings.Contains(srcSpec, "{z}") || strings.Contains(srcSpec, "{q}"):
+		ds, err := tiles.NewDirSource(srcSpec)
+		if err != nil { return l, nil, err }
+		l.Src, l.Preset = ds, ds.Preset
+		return l, nil, nil
+	}
+	return l, nil, fmt.Errorf("не понял источник %q: ожидается пресет, URL-шаблон, .mbtiles, .pmtiles или папка с {z}/{x}/{y}", srcSpec)
+}
+
+// атрибуция всех слоёв без повторов
+func layerAttribution(layers []tiles.Layer) string {
+	var parts []string
+	seen := map[string]bool{}
+	for _, l := range layers {
+		a := strings.TrimSpace(l.Preset.Attribution)
+		if a == "" || seen[a] { continue }
+		seen[a] = true
+		parts = append(parts, a)
+	}
+	return strings.Join(parts, " | ")
+}
+
+// ---- helper: стиль текста из флагов ----
+
+func loadTextStyle(size float64) (tiles.TextStyle, error) {
+	face, err := tiles.LoadFace(*fontPath, size)
+	if err != nil {
+		return tiles.TextStyle{}, err
+	}
+	st := tiles.DefaultTextStyle(face)
+	st.OutlineWidth = *textOutlineW
+	if st.Color, err = ParseHexColor(*textHex); err != nil {
+		return st, fmt.Errorf("textColor: %w", err)
+	}
+	st.Outline = nil
+	if strings.TrimSpace(*textOutlineHex) != "" {
+		if st.Outline, err = ParseHexColor(*textOutlineHex); err != nil {
+			return st, fmt.Errorf("textOutline: %w", err)
+		}
+	}
+	st.Panel = nil
+	if strings.TrimSpace(*textPanelHex) != "" {
+		if st.Panel, err = ParseHexColor(*textPanelHex); err != nil {
+			return st, fmt.Errorf("textPanel: %w", err)
+		}
+	}
+	return st, nil
+}
+
 // ---- helper: подгонка карты под квадратный кадр ----
 
 func fitBaseToCanvas(src image.Image, W, H int, mode string, bg color.Color) image.Image {
@@ -334,6 +945,24 @@ func fitBaseToCanvas(src image.Image, W, H int, mode string, bg color.Color) ima
 	return dst
 }
 
+// подложка как *image.RGBA размера W×H, чтобы рисовать поверх неё; без карты — заливка bg
+func baseCanvas(base image.Image, W, H int, bg color.Color) *image.RGBA {
+	if rgba, ok := base.(*image.RGBA); ok && rgba.Bounds() == image.Rect(0, 0, W, H) {
+		return rgba
+	}
+	return copyCanvas(base, W, H, bg)
+}
+
+// как baseCanvas, но всегда новый холст: исходник не меняется
+func copyCanvas(base image.Image, W, H int, bg color.Color) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, W, H))
+	fillRGBA(dst, bg)
+	if base != nil {
+		xdraw.Copy(dst, image.Point{}, base, base.Bounds(), xdraw.Over, nil)
+	}
+	return dst
+}
+
 func fillRGBA(dst *image.RGBA, c color.Color) {
 	b := dst.Bounds()
 	for y := b.Min.Y; y < b.Max.Y; y++ {
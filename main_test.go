@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/s0ultr4d3r/psstelebot/tiles"
+)
+
+func TestOpenTileLayer(t *testing.T) {
+	fetcher, err := tiles.NewFetcher(t.TempDir(), 10, 1, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := tiles.Secrets{"LAYER_TEST_KEY": "layer-key-1"}
+	for _, tc := range []struct {
+		spec    string
+		tmpl    string // URL template of the source
+		opacity float64
+		blend   tiles.BlendMode
+	}{
+		{"/data/relief/{z}/{x}/{y}.png", "/data/relief/{z}/{x}/{y}.png", 1, ""},
+		{"/data/relief/{z}/{x}/{y}.png,opacity=0.6", "/data/relief/{z}/{x}/{y}.png", 0.6, ""},
+		{"/data/relief/{z}/{x}/{y}.png, blend=Multiply , opacity=0", "/data/relief/{z}/{x}/{y}.png", 0, tiles.BlendMultiply},
+		{"/data/relief/{z}/{x}/{y}.png,OPACITY=1,blend=screen", "/data/relief/{z}/{x}/{y}.png", 1, tiles.BlendScreen},
+		// commas inside the URL stay with the source
+		{"https://t.example/{z}/{x}/{y}.png?layers=a,b,opacity=0.5", "https://t.example/{z}/{x}/{y}.png?layers=a,b", 0.5, ""},
+		{"https://t.example/{z}/{x}/{y}.png?style=x=1,y", "https://t.example/{z}/{x}/{y}.png?style=x=1,y", 1, ""},
+		{"opentopomap,blend=overlay", tiles.Presets["opentopomap"].URLTmpl, 1, tiles.BlendOverlay},
+		// только opacity и blend — опции слоя, прочие k=v остаются в URL
+		{"https://h/{z}/{x}/{y}.png?style=a,b=2", "https://h/{z}/{x}/{y}.png?style=a,b=2", 1, ""},
+		{"https://h/{z}/{x}/{y}.png?key=${LAYER_TEST_KEY},lang=en", "https://h/{z}/{x}/{y}.png?key=layer-key-1,lang=en", 1, ""},
+		{"https://h/{z}/{x}/{y}.png?key=${LAYER_TEST_KEY},lang=en,blend=darken", "https://h/{z}/{x}/{y}.png?key=layer-key-1,lang=en", 1, tiles.BlendDarken},
+		{"/d/{z}/{x}/{y}.png,size=3", "/d/{z}/{x}/{y}.png,size=3", 1, ""},
+	} {
+		l, closer, err := openTileLayer(context.Background(), fetcher, secrets, tc.spec)
+		if err != nil {
+			t.Errorf("%q: %v", tc.spec, err)
+			continue
+		}
+		if closer != nil {
+			closer.Close()
+		}
+		if l.Src == nil || l.Preset.URLTmpl != tc.tmpl || l.Opacity != tc.opacity || l.Blend != tc.blend {
+			t.Errorf("%q: template %q, opacity %v, blend %q; want %q, %v, %q",
+				tc.spec, l.Preset.URLTmpl, l.Opacity, l.Blend, tc.tmpl, tc.opacity, tc.blend)
+		}
+	}
+}
+
+func TestOpenTileLayerErrors(t *testing.T) {
+	fetcher, err := tiles.NewFetcher(t.TempDir(), 10, 1, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct{ spec, want string }{
+		{"/d/{z}/{x}/{y}.png,opacity=1.5", "opacity"},
+		{"/d/{z}/{x}/{y}.png,opacity=-0.1", "opacity"},
+		{"/d/{z}/{x}/{y}.png,opacity=half", "opacity"},
+		{"/d/{z}/{x}/{y}.png,blend=burn", "unknown blend mode"},
+		{"/d/{z}/{x}/{y}.png,opacity", "opacity"},
+		{"no-such-preset,opacity=0.5", "не понял источник"},
+		{"https://t.example/{z}/{x}.png", "needs {z}, {x} and {y}"},
+	} {
+		_, closer, err := openTileLayer(context.Background(), fetcher, nil, tc.spec)
+		if closer != nil {
+			closer.Close()
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%q: error %v, want %q", tc.spec, err, tc.want)
+		}
+	}
+}
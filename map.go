@@ -11,6 +11,8 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/s0ultr4d3r/psstelebot/tiles"
 )
 
 func expandStaticURL(tpl string, bb boundsLL, w, h int) string {
@@ -33,17 +35,19 @@ func fetchStaticMap(ctx context.Context, url string) (image.Image, error) {
 	url = strings.TrimSpace(url)
 	client := &http.Client{Timeout: 30 * time.Second}
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil { return nil, err }
+	// в тексте ошибки разбора — URL целиком, вместе с подставленными ключами
+	if err != nil { return nil, tiles.RedactErr(err) }
 	resp, err := client.Do(req)
-	if err != nil { return nil, err }
+	if err != nil { return nil, tiles.RedactErr(err) }
 	defer resp.Body.Close()
 
 	if resp.StatusCode/100 != 2 {
+		// некоторые серверы повторяют ключ в тексте ошибки
 		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
-		return nil, fmt.Errorf("map HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(b)))
+		return nil, fmt.Errorf("map HTTP %d: %s", resp.StatusCode, tiles.Redact(strings.TrimSpace(string(b))))
 	}
 	buf, err := io.ReadAll(resp.Body)
-	if err != nil { return nil, err }
+	if err != nil { return nil, tiles.RedactErr(err) }
 	if img, err := png.Decode(bytes.NewReader(buf)); err == nil { return img, nil }
 	return jpeg.Decode(bytes.NewReader(buf))
 }
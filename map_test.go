@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/s0ultr4d3r/psstelebot/tiles"
+)
+
+func TestFetchStaticMapRedactsKey(t *testing.T) {
+	const key = "static-map-key-777"
+	tiles.RegisterSecret(key)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// как некоторые провайдеры: ключ повторяется в теле ошибки
+		http.Error(w, "invalid key "+r.URL.Query().Get("key"), http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	for _, u := range []string{
+		srv.URL + "/map.png?key=" + key,
+		"http://bad host/map.png?key=" + key, // ошибка разбора URL
+	} {
+		_, err := fetchStaticMap(context.Background(), u)
+		if err == nil {
+			t.Fatalf("%s: no error", u)
+		}
+		if strings.Contains(err.Error(), key) {
+			t.Errorf("key leaked: %v", err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// фильтр подложки: приглушает карту, чтобы треки читались лучше
+type mapFilter struct {
+	name string
+	v    float64
+	tint color.NRGBA
+}
+
+// разбирает список вида "gray,dim=0.4,blur=1.5,tint=#40ff8800"; фильтры применяются по порядку
+func parseMapFilters(spec string) ([]mapFilter, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" { return nil, nil }
+	var out []mapFilter
+	for _, item := range strings.Split(spec, ",") {
+		name, arg, hasArg := strings.Cut(strings.TrimSpace(item), "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		arg = strings.TrimSpace(arg)
+		f := mapFilter{name: name}
+
+		num := func(def, lo, hi float64) error {
+			f.v = def
+			if !hasArg { return nil }
+			v, err := strconv.ParseFloat(arg, 64)
+			if err != nil || v < lo || v > hi {
+				return fmt.Errorf("mapFilter %s: значение %q вне диапазона [%g..%g]", name, arg, lo, hi)
+			}
+			f.v = v
+			return nil
+		}
+		var err error
+		switch name {
+		case "gray", "grayscale":
+			f.name = "desat"
+			f.v = 1
+		case "desat", "desaturate":
+			f.name = "desat"
+			err = num(0.5, 0, 1)
+		case "dim":
+			err = num(0.3, 0, 1)
+		case "bright", "brightness":
+			f.name = "bright"
+			err = num(0.2, 0, 1)
+		case "contrast":
+			err = num(1.3, 0, 4)
+		case "blur":
+			err = num(1.5, 0, 50)
+		case "tint":
+			if !hasArg { return nil, fmt.Errorf("mapFilter tint: нужен цвет, например tint=#40ff8800") }
+			c, cerr := ParseHexColor(arg)
+			if cerr != nil { return nil, fmt.Errorf("mapFilter tint: %w", cerr) }
+			f.tint = color.NRGBAModel.Convert(c).(color.NRGBA)
+			// без альфы в цвете — умеренная сила тонирования
+			f.v = 0.3
+			if len(strings.TrimPrefix(arg, "#")) == 8 { f.v = float64(f.tint.A) / 255 }
+		default:
+			return nil, fmt.Errorf("неизвестный mapFilter: %q (gray|desat|dim|bright|contrast|tint|blur)", name)
+		}
+		if err != nil { return nil, err }
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+// применяет фильтры к подложке на месте
+func applyMapFilters(img *image.RGBA, fs []mapFilter) {
+	for _, f := range fs {
+		switch f.name {
+		case "blur":
+			gaussBlurRGBA(img, f.v)
+		default:
+			eachPixel(img, func(r, g, b float64) (float64, float64, float64) { return f.pixel(r, g, b) })
+		}
+	}
+}
+
+// попиксельные фильтры, каналы в [0..255]
+func (f mapFilter) pixel(r, g, b float64) (float64, float64, float64) {
+	switch f.name {
+	case "desat":
+		l := 0.299*r + 0.587*g + 0.114*b
+		return r + (l-r)*f.v, g + (l-g)*f.v, b + (l-b)*f.v
+	case "dim":
+		k := 1 - f.v
+		return r * k, g * k, b * k
+	case "bright":
+		return r + (255-r)*f.v, g + (255-g)*f.v, b + (255-b)*f.v
+	case "contrast":
+		c := func(x float64) float64 { return (x-127.5)*f.v + 127.5 }
+		return c(r), c(g), c(b)
+	case "tint":
+		t := f.tint
+		return r + (float64(t.R)-r)*f.v, g + (float64(t.G)-g)*f.v, b + (float64(t.B)-b)*f.v
+	}
+	return r, g, b
+}
+
+func eachPixel(img *image.RGBA, fn func(r, g, b float64) (float64, float64, float64)) {
+	clamp := func(v float64) uint8 { return uint8(math.Max(0, math.Min(255, math.Round(v)))) }
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(b.Min.X, y):img.PixOffset(b.Max.X, y)]
+		for i := 0; i+3 < len(row); i += 4 {
+			r, g, bl := fn(float64(row[i]), float64(row[i+1]), float64(row[i+2]))
+			row[i], row[i+1], row[i+2] = clamp(r), clamp(g), clamp(bl)
+		}
+	}
+}
+
+// размытие по Гауссу: два прохода одномерным ядром, края — повтором крайнего пикселя
+func gaussBlurRGBA(img *image.RGBA, sigma float64) {
+	if sigma <= 0 { return }
+	rad := int(math.Ceil(sigma * 3))
+	k := make([]float64, 2*rad+1)
+	sum := 0.0
+	for i := range k {
+		x := float64(i - rad)
+		k[i] = math.Exp(-x * x / (2 * sigma * sigma))
+		sum += k[i]
+	}
+	for i := range k { k[i] /= sum }
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	tmp := make([]float64, w*h*4)
+	px := func(x, y int) []uint8 { i := img.PixOffset(b.Min.X+x, b.Min.Y+y); return img.Pix[i : i+4] }
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var acc [4]float64
+			for j, kv := range k {
+				p := px(clampInt(x+j-rad, 0, w-1), y)
+				for c := 0; c < 4; c++ { acc[c] += float64(p[c]) * kv }
+			}
+			copy(tmp[(y*w+x)*4:], acc[:])
+		}
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var acc [4]float64
+			for j, kv := range k {
+				yy := clampInt(y+j-rad, 0, h-1)
+				for c := 0; c < 4; c++ { acc[c] += tmp[(yy*w+x)*4+c] * kv }
+			}
+			p := px(x, y)
+			for c := 0; c < 4; c++ { p[c] = uint8(math.Min(255, math.Round(acc[c]))) }
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestParseMapFilters(t *testing.T) {
+	for _, tc := range []struct {
+		spec string
+		want []mapFilter
+	}{
+		{"", nil},
+		{"  ", nil},
+		{"gray", []mapFilter{{name: "desat", v: 1}}},
+		{"Grayscale, dim", []mapFilter{{name: "desat", v: 1}, {name: "dim", v: 0.3}}},
+		{"desaturate=0.25,bright,contrast=2", []mapFilter{{name: "desat", v: 0.25}, {name: "bright", v: 0.2}, {name: "contrast", v: 2}}},
+		{"blur = 0 ,dim=1", []mapFilter{{name: "blur", v: 0}, {name: "dim", v: 1}}},
+		{"tint=#ff8800", []mapFilter{{name: "tint", v: 0.3, tint: color.NRGBA{0xff, 0x88, 0x00, 0xff}}}},
+		{"tint=#80ff8800", []mapFilter{{name: "tint", v: 128.0 / 255, tint: color.NRGBA{0xff, 0x88, 0x00, 0x80}}}},
+	} {
+		got, err := parseMapFilters(tc.spec)
+		if err != nil {
+			t.Errorf("%q: %v", tc.spec, err)
+			continue
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("%q: %v, want %v", tc.spec, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] { t.Errorf("%q: filter %d = %+v, want %+v", tc.spec, i, got[i], tc.want[i]) }
+		}
+	}
+}
+
+func TestParseMapFiltersErrors(t *testing.T) {
+	for _, tc := range []struct{ spec, msg string }{
+		{"sepia", "неизвестный mapFilter"},
+		{"gray,", "неизвестный mapFilter"},
+		{"dim=1.5", "вне диапазона"},
+		{"dim=-0.1", "вне диапазона"},
+		{"desat=half", "вне диапазона"},
+		{"contrast=5", "вне диапазона"},
+		{"blur=51", "вне диапазона"},
+		{"bright=", "вне диапазона"},
+		{"tint", "нужен цвет"},
+		{"tint=ff8800", "tint"},
+		{"tint=#ff88", "tint"},
+	} {
+		_, err := parseMapFilters(tc.spec)
+		if err == nil || !strings.Contains(err.Error(), tc.msg) {
+			t.Errorf("%q: error %v, want one with %q", tc.spec, err, tc.msg)
+		}
+	}
+}
+
+func TestApplyMapFilters(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for i := 0; i < len(img.Pix); i += 4 { copy(img.Pix[i:], []uint8{200, 100, 50, 255}) }
+	fs, err := parseMapFilters("gray,dim=0.5")
+	if err != nil { t.Fatal(err) }
+	applyMapFilters(img, fs)
+	// яркость 0.299*200+0.587*100+0.114*50 = 124.2, затем вдвое темнее
+	if got := img.RGBAAt(2, 2); got != (color.RGBA{62, 62, 62, 255}) { t.Errorf("gray,dim=0.5: %v", got) }
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+
+	"github.com/s0ultr4d3r/psstelebot/tiles"
+)
+
+// виды маркера «головы» трека (текущая позиция)
+const (
+	markerNone   = "none"
+	markerCircle = "circle"
+	markerArrow  = "arrow"
+	markerAvatar = "avatar"
+)
+
+func parseMarker(s string) (string, error) {
+	switch s = strings.ToLower(strings.TrimSpace(s)); s {
+	case "", markerNone:
+		return markerNone, nil
+	case markerCircle, markerArrow, markerAvatar:
+		return s, nil
+	}
+	return "", fmt.Errorf("неизвестный marker: %q (none|circle|arrow|avatar)", s)
+}
+
+// загружает PNG/JPEG аватарки (через запятую), масштабирует до size и обрезает кругом
+func loadAvatars(csv string, size int) ([]image.Image, error) {
+	csv = strings.TrimSpace(csv)
+	if csv == "" { return nil, nil }
+	var out []image.Image
+	for _, p := range strings.Split(csv, ",") {
+		p = strings.TrimSpace(p)
+		f, err := os.Open(p)
+		if err != nil { return nil, fmt.Errorf("avatar %s: %w", p, err) }
+		src, _, err := image.Decode(f)
+		f.Close()
+		if err != nil { return nil, fmt.Errorf("avatar %s: %w", p, err) }
+
+		scaled := image.NewRGBA(image.Rect(0, 0, size, size))
+		xdraw.CatmullRom.Scale(scaled, scaled.Bounds(), src, src.Bounds(), xdraw.Src, nil)
+
+		av := image.NewRGBA(scaled.Bounds())
+		draw.DrawMask(av, av.Bounds(), scaled, image.Point{}, discMask(size), image.Point{}, draw.Src)
+		out = append(out, av)
+	}
+	return out, nil
+}
+
+// круглая маска size×size
+func discMask(size int) *image.Alpha {
+	m := image.NewAlpha(image.Rect(0, 0, size, size))
+	c := float64(size-1) / 2
+	r := float64(size) / 2
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if math.Hypot(float64(x)-c, float64(y)-c) <= r { m.Pix[m.PixOffset(x, y)] = 0xFF }
+		}
+	}
+	return m
+}
+
+// рисует маркеры и подписи в текущих позициях треков
+func (r *frameRenderer) drawHeads(dst *image.RGBA, st frameState) {
+	if r.opts.Marker == markerNone && len(r.opts.Labels) == 0 { return }
+	size := r.opts.MarkerSize
+	if size < 4 { size = 4 }
+
+	for tIdx, pts := range r.tracks {
+		i := st.End[tIdx]
+		if i < 0 || len(pts) == 0 { continue }
+		if i > len(pts)-1 { i = len(pts) - 1 }
+		x, y := r.pt(pts[i])
+		if !image.Pt(x, y).In(dst.Rect.Inset(-size)) { continue } // голова за кадром
+		col := r.opts.Colors[tIdx%len(r.opts.Colors)]
+		if tIdx == r.leader && r.opts.Marker != markerNone { fillDisc(dst, x, y, size/2+4, leaderRing) }
+
+		switch r.opts.Marker {
+		case markerCircle:
+			fillDisc(dst, x, y, size/2+1, color.White)
+			fillDisc(dst, x, y, size/2-1, col)
+		case markerArrow:
+			drawArrow(dst, x, y, r.heading(pts, i), size, col)
+		case markerAvatar:
+			if tIdx < len(r.opts.Avatars) && r.opts.Avatars[tIdx] != nil {
+				fillDisc(dst, x, y, size/2+1, col)
+				av := r.opts.Avatars[tIdx]
+				at := image.Pt(x-size/2, y-size/2)
+				draw.Draw(dst, av.Bounds().Add(at), av, image.Point{}, draw.Over)
+			} else {
+				fillDisc(dst, x, y, size/2+1, color.White)
+				fillDisc(dst, x, y, size/2-1, col)
+			}
+		}
+
+		if tIdx < len(r.opts.Labels) && r.opts.Labels[tIdx] != "" {
+			drawLabel(dst, x+size/2+4, y, r.opts.Labels[tIdx], r.opts.Text, col)
+		}
+	}
+}
+
+// направление движения в экранных координатах (радианы, 0 = вправо, по часовой),
+// берём точку позади, отстоящую хотя бы на несколько пикселей — иначе стрелка дёргается
+func (r *frameRenderer) heading(pts []PtLL, i int) float64 {
+	x, y := r.pt(pts[i])
+	for k := i - 1; k >= 0; k-- {
+		px, py := r.pt(pts[k])
+		if dx, dy := x-px, y-py; dx*dx+dy*dy >= 9 {
+			return math.Atan2(float64(dy), float64(dx))
+		}
+	}
+	if i+1 < len(pts) {
+		nx, ny := r.pt(pts[i+1])
+		if nx != x || ny != y { return math.Atan2(float64(ny-y), float64(nx-x)) }
+	}
+	return -math.Pi / 2
+}
+
+func fillDisc(dst *image.RGBA, cx, cy, rad int, c color.Color) {
+	if rad <= 0 { return }
+	r2 := rad * rad
+	for y := -rad; y <= rad; y++ {
+		for x := -rad; x <= rad; x++ {
+			if x*x+y*y > r2 { continue }
+			if p := image.Pt(cx+x, cy+y); p.In(dst.Rect) { dst.Set(p.X, p.Y, c) }
+		}
+	}
+}
+
+// стрелка-треугольник с белой каймой, носом по направлению ang
+func drawArrow(dst *image.RGBA, cx, cy int, ang float64, size int, c color.Color) {
+	// центр пикселя (cx, cy), как у кругов маркеров
+	fx, fy := float64(cx)+0.5, float64(cy)+0.5
+	tri := func(s float64, c color.Color) {
+		var p [3][2]float64
+		for k, a := range []float64{0, 2.5, -2.5} {
+			rr := s
+			if k > 0 { rr = s * 0.8 }
+			p[k] = [2]float64{fx + rr*math.Cos(ang+a), fy + rr*math.Sin(ang+a)}
+		}
+		tiles.FillTriangle(dst, p[0], p[1], p[2], c)
+	}
+	tri(float64(size)*0.75, color.White)
+	tri(float64(size)*0.75-2, c)
+}
+
+// подпись цветом трека, (x,y) — левый край по центру строки
+func drawLabel(dst *image.RGBA, x, y int, text string, st tiles.TextStyle, c color.Color) {
+	st.Color = c
+	_, h := st.BoxSize(text)
+	tiles.DrawText(dst, x, y-h/2, text, st)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// врезка-обзор: весь маршрут целиком и рамка области, видимой в кадре
+type MinimapOpts struct {
+	Enabled bool
+	Corner  string
+	Size    int         // сторона врезки, px
+	Base    image.Image // подложка всего bbox; nil — однотонная плашка
+}
+
+// статичный слой врезки: подложка и все треки; по кадрам меняется только рамка
+func (r *frameRenderer) buildMinimap() {
+	mo := r.opts.Minimap
+	if !mo.Enabled { return }
+	s := max(32, mo.Size)
+	lay := image.NewRGBA(image.Rect(0, 0, s, s))
+	if mo.Base != nil {
+		xdraw.ApproxBiLinear.Scale(lay, lay.Bounds(), mo.Base, mo.Base.Bounds(), xdraw.Src, nil)
+	} else {
+		draw.Draw(lay, lay.Bounds(), image.NewUniform(color.RGBA{20, 20, 20, 200}), image.Point{}, draw.Src)
+	}
+	for tIdx, pts := range r.tracks {
+		col := r.opts.Colors[tIdx%len(r.opts.Colors)]
+		for k := 0; k+1 < len(pts); k++ {
+			x1, y1 := projectFree(pts[k], r.bb, s)
+			x2, y2 := projectFree(pts[k+1], r.bb, s)
+			drawLineRGBA(lay, x1, y1, x2, y2, 1, col)
+		}
+	}
+	strokeRect(lay, lay.Bounds(), color.RGBA{255, 255, 255, 220})
+	r.minimap = lay
+}
+
+func (r *frameRenderer) drawMinimap(dst *image.RGBA, hud *hudStack, st frameState) {
+	if r.minimap == nil { return }
+	s := r.minimap.Bounds().Dx()
+	at := hud.place(r.opts.Minimap.Corner, s, s)
+	draw.Draw(dst, r.minimap.Bounds().Add(at), r.minimap, image.Point{}, draw.Over)
+
+	// рамка текущего вида камеры в координатах врезки
+	x1, y1 := projectFree(PtLL{Lat: st.View.maxLat, Lon: st.View.minLon}, r.bb, s)
+	x2, y2 := projectFree(PtLL{Lat: st.View.minLat, Lon: st.View.maxLon}, r.bb, s)
+	vr := image.Rect(x1, y1, x2+1, y2+1).Add(at).Intersect(r.minimap.Bounds().Add(at))
+	strokeRect(dst, vr, color.RGBA{255, 204, 0, 255})
+
+	// текущие позиции
+	for tIdx, pts := range r.tracks {
+		i := st.End[tIdx]
+		if i < 0 || len(pts) == 0 { continue }
+		x, y := projectFree(pts[min(i, len(pts)-1)], r.bb, s)
+		fillDisc(dst, at.X+x, at.Y+y, 2, r.opts.Colors[tIdx%len(r.opts.Colors)])
+	}
+}
+
+// рамка толщиной 1 px по краю прямоугольника
+func strokeRect(dst *image.RGBA, rc image.Rectangle, c color.Color) {
+	if rc.Empty() { return }
+	u := image.NewUniform(c)
+	for _, e := range []image.Rectangle{
+		image.Rect(rc.Min.X, rc.Min.Y, rc.Max.X, rc.Min.Y+1),
+		image.Rect(rc.Min.X, rc.Max.Y-1, rc.Max.X, rc.Max.Y),
+		image.Rect(rc.Min.X, rc.Min.Y, rc.Min.X+1, rc.Max.Y),
+		image.Rect(rc.Max.X-1, rc.Min.Y, rc.Max.X, rc.Max.Y),
+	} {
+		draw.Draw(dst, e, u, image.Point{}, draw.Over)
+	}
+}
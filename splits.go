@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"time"
+
+	"github.com/s0ultr4d3r/psstelebot/tiles"
+)
+
+// отметки на маршруте: старт/финиш и сплиты каждые Every метров
+type SplitOpts struct {
+	StartFinish bool
+	Every       float64 // интервал сплитов, м; 0 — без сплитов
+	Units       string
+}
+
+// отметка сплита: появляется, когда голова трека проходит точку idx
+type splitMark struct {
+	idx   int // первая точка после отметки
+	at    PtLL
+	label string
+}
+
+// находит отметки сплитов по накопленной дистанции; время сплита
+// интерполируется между соседними точками
+func (r *frameRenderer) buildSplits() {
+	so := r.opts.Splits
+	if so.Every <= 0 { return }
+	r.splits = make([][]splitMark, len(r.tracks))
+	for tIdx, pts := range r.tracks {
+		d := r.dist[tIdx]
+		var prevT *time.Time
+		if len(pts) > 0 { prevT = pts[0].T }
+		n := 1
+		for k := 1; k < len(pts); k++ {
+			for d[k] >= float64(n)*so.Every {
+				m := float64(n) * so.Every
+				f := 0.0
+				if seg := d[k] - d[k-1]; seg > 0 { f = (m - d[k-1]) / seg }
+				a, b := pts[k-1], pts[k]
+				at := PtLL{Lat: a.Lat + (b.Lat-a.Lat)*f, Lon: a.Lon + (b.Lon-a.Lon)*f}
+
+				label := fmt.Sprintf("%g км", float64(n)*so.Every/1000)
+				if so.Units == unitsImperial { label = fmt.Sprintf("%g mi", float64(n)*so.Every/1609.344) }
+				if a.T != nil && b.T != nil && prevT != nil {
+					t := a.T.Add(time.Duration(float64(b.T.Sub(*a.T)) * f))
+					label += " " + fmtSplit(t.Sub(*prevT))
+					prevT = &t
+				}
+				r.splits[tIdx] = append(r.splits[tIdx], splitMark{idx: k, at: at, label: label})
+				n++
+			}
+		}
+	}
+}
+
+// рисует пройденные к кадру отметки: сплиты, старт, финиш
+func (r *frameRenderer) drawSplits(dst *image.RGBA, st frameState) {
+	so := r.opts.Splits
+	if !so.StartFinish && r.splits == nil { return }
+	ts := r.opts.Text
+	ts.Padding = 1
+
+	for tIdx, pts := range r.tracks {
+		i := st.End[tIdx]
+		if i < 0 || len(pts) == 0 { continue }
+		col := r.opts.Colors[tIdx%len(r.opts.Colors)]
+
+		if r.splits != nil {
+			for _, s := range r.splits[tIdx] {
+				if s.idx > i { break }
+				x, y := r.pt(s.at)
+				if !image.Pt(x, y).In(dst.Rect.Inset(-8)) { continue }
+				fillDisc(dst, x, y, 5, color.RGBA{30, 30, 30, 255})
+				fillDisc(dst, x, y, 4, col)
+				drawLabel(dst, x+7, y, s.label, ts, col)
+			}
+		}
+
+		if !so.StartFinish { continue }
+		x, y := r.pt(pts[0])
+		drawStart(dst, x, y, col)
+		if i >= len(pts)-1 {
+			x, y = r.pt(pts[len(pts)-1])
+			drawFinish(dst, x, y)
+		}
+	}
+}
+
+// старт — белый круг с треугольником «play» цвета трека
+func drawStart(dst *image.RGBA, cx, cy int, c color.Color) {
+	fillDisc(dst, cx, cy, 8, color.RGBA{30, 30, 30, 255})
+	fillDisc(dst, cx, cy, 7, color.White)
+	fx, fy := float64(cx)+0.5, float64(cy)+0.5
+	tiles.FillTriangle(dst, [2]float64{fx - 3, fy - 5}, [2]float64{fx - 3, fy + 5}, [2]float64{fx + 5, fy}, c)
+}
+
+// финиш — клетчатый флажок на древке, древко стоит в точке финиша
+func drawFinish(dst *image.RGBA, cx, cy int) {
+	const cell, cols, rows = 3, 5, 3
+	dark := image.NewUniform(color.RGBA{30, 30, 30, 255})
+	top := cy - 16
+	draw.Draw(dst, image.Rect(cx-1, top, cx+1, cy+1), dark, image.Point{}, draw.Src)
+
+	flag := image.Rect(cx+1, top, cx+1+cols*cell, top+rows*cell)
+	draw.Draw(dst, flag.Inset(-1), dark, image.Point{}, draw.Src)
+	for j := 0; j < rows; j++ {
+		for i := 0; i < cols; i++ {
+			if (i+j)%2 != 0 { continue }
+			c := image.Rect(flag.Min.X+i*cell, flag.Min.Y+j*cell, flag.Min.X+(i+1)*cell, flag.Min.Y+(j+1)*cell)
+			draw.Draw(dst, c, image.White, image.Point{}, draw.Src)
+		}
+	}
+}
+
+// время сплита: m:ss или h:mm:ss
+func fmtSplit(d time.Duration) string {
+	s := int(math.Round(d.Seconds()))
+	if s >= 3600 { return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60) }
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
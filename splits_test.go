@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildSplits(t *testing.T) {
+	t0 := time.Date(2025, 5, 31, 10, 0, 0, 0, time.UTC)
+	// 2.6 км на север, точка каждые 100 м; первый км за 5:00, дальше по 6:00
+	var timed, bare []PtLL
+	for i := 0; i <= 26; i++ {
+		p := ptM(float64(i)*100, 0)
+		bare = append(bare, p)
+		s := i * 30
+		if i > 10 { s = 300 + (i-10)*36 }
+		tt := t0.Add(time.Duration(s) * time.Second)
+		p.T = &tt
+		timed = append(timed, p)
+	}
+	for _, tc := range []struct {
+		name  string
+		pts   []PtLL
+		every float64
+		units string
+		want  []string
+	}{
+		{"timed", timed, 1000, unitsMetric, []string{"1 км 5:00", "2 км 6:00"}},
+		{"no timestamps", bare, 1000, unitsMetric, []string{"1 км", "2 км"}},
+		{"half km", bare, 500, unitsMetric, []string{"0.5 км", "1 км", "1.5 км", "2 км", "2.5 км"}},
+		{"miles", timed, 1609.344, unitsImperial, []string{"1 mi 8:39"}},
+		{"single point", timed[:1], 1000, unitsMetric, nil},
+	} {
+		r := &frameRenderer{tracks: [][]PtLL{tc.pts}, dist: [][]float64{cumDist(tc.pts)}}
+		r.opts.Splits = SplitOpts{Every: tc.every, Units: tc.units}
+		r.buildSplits()
+		got := r.splits[0]
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: %d splits %v, want %v", tc.name, len(got), got, tc.want)
+			continue
+		}
+		for i, m := range got {
+			if m.label != tc.want[i] { t.Errorf("%s: split %d %q, want %q", tc.name, i, m.label, tc.want[i]) }
+			// отметка лежит между точками idx-1 и idx
+			if d := r.dist[0]; d[m.idx-1] > tc.every*float64(i+1) || d[m.idx] < tc.every*float64(i+1) {
+				t.Errorf("%s: split %d at point %d (%.0f..%.0f m)", tc.name, i, m.idx, d[m.idx-1], d[m.idx])
+			}
+		}
+	}
+
+	r := &frameRenderer{tracks: [][]PtLL{timed}, dist: [][]float64{cumDist(timed)}}
+	r.buildSplits()
+	if r.splits != nil { t.Error("splits built with Every = 0") }
+}
+
+func TestFmtSplit(t *testing.T) {
+	for _, tc := range []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0:00"},
+		{59*time.Second + 600*time.Millisecond, "1:00"},
+		{5*time.Minute + 7*time.Second, "5:07"},
+		{time.Hour + 2*time.Minute + 3*time.Second, "1:02:03"},
+	} {
+		if got := fmtSplit(tc.d); got != tc.want { t.Errorf("fmtSplit(%v) = %q, want %q", tc.d, got, tc.want) }
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"time"
+
+	"github.com/s0ultr4d3r/psstelebot/tiles"
+)
+
+// единицы измерения в HUD
+const (
+	unitsMetric   = "metric"
+	unitsImperial = "imperial"
+)
+
+func parseUnits(s string) (string, error) {
+	switch s = strings.ToLower(strings.TrimSpace(s)); s {
+	case unitsMetric, unitsImperial:
+		return s, nil
+	}
+	return "", fmt.Errorf("неизвестные units: %q (metric|imperial)", s)
+}
+
+// панель статистики по трекам: дистанция, скорость/темп, высота, пульс
+type StatsOpts struct {
+	Enabled bool
+	Corner  string
+	Units   string
+	Pace    bool          // темп (мин/км) вместо скорости
+	Window  time.Duration // окно сглаживания скорости
+}
+
+const maxStatsTracks = 6
+
+func (r *frameRenderer) drawStats(dst *image.RGBA, hud *hudStack, st frameState) {
+	so := r.opts.Stats
+	if !so.Enabled { return }
+
+	n := min(len(r.tracks), maxStatsTracks)
+	lines := make([]string, 0, n)
+	idx := make([]int, 0, n)
+	for tIdx := 0; tIdx < n; tIdx++ {
+		i := st.End[tIdx]
+		pts := r.tracks[tIdx]
+		if len(pts) == 0 { continue }
+		if i > len(pts)-1 { i = len(pts) - 1 }
+
+		parts := []string{}
+		if tIdx < len(r.opts.Names) { parts = append(parts, r.opts.Names[tIdx]) }
+		if i < 0 {
+			parts = append(parts, "—")
+		} else {
+			parts = append(parts, fmtDist(r.dist[tIdx][i], so.Units))
+			if v, ok := r.speedAt(tIdx, i, so.Window); ok {
+				if so.Pace {
+					parts = append(parts, fmtPace(v, so.Units))
+				} else {
+					parts = append(parts, fmtSpeed(v, so.Units))
+				}
+			}
+			if e := pts[i].Ele; e != nil { parts = append(parts, fmtEle(*e, so.Units)) }
+			if hr := pts[i].HR; hr > 0 { parts = append(parts, fmt.Sprintf("♥%d", hr)) }
+		}
+		lines = append(lines, strings.Join(parts, "  "))
+		idx = append(idx, tIdx)
+	}
+
+	// каждая строка — цветом своего трека
+	order := make([]int, len(lines))
+	for k := range order { order[k] = k }
+	if so.Corner == cornerBL || so.Corner == cornerBR {
+		for k := range order { order[k] = len(lines) - 1 - k }
+	}
+	for _, k := range order {
+		ts := r.opts.Text
+		ts.Color = r.opts.Colors[idx[k]%len(r.opts.Colors)]
+		w, h := ts.BoxSize(lines[k])
+		p := hud.place(so.Corner, w, h)
+		tiles.DrawText(dst, p.X, p.Y, lines[k], ts)
+	}
+}
+
+// скорость в точке i, м/с: дистанция за последние window по времени точек
+func (r *frameRenderer) speedAt(tIdx, i int, window time.Duration) (float64, bool) {
+	pts := r.tracks[tIdx]
+	if i < 1 || pts[i].T == nil { return 0, false }
+	if window <= 0 { window = 30 * time.Second }
+	k := i - 1
+	for k > 0 && pts[k].T != nil && pts[i].T.Sub(*pts[k].T) < window { k-- }
+	if pts[k].T == nil { return 0, false }
+	dt := pts[i].T.Sub(*pts[k].T).Seconds()
+	if dt <= 0 { return 0, false }
+	return (r.dist[tIdx][i] - r.dist[tIdx][k]) / dt, true
+}
+
+func fmtDist(m float64, units string) string {
+	if units == unitsImperial { return fmt.Sprintf("%.2f mi", m/1609.344) }
+	return fmt.Sprintf("%.2f км", m/1000)
+}
+
+func fmtSpeed(ms float64, units string) string {
+	if units == unitsImperial { return fmt.Sprintf("%.1f mph", ms*2.236936) }
+	return fmt.Sprintf("%.1f км/ч", ms*3.6)
+}
+
+func fmtPace(ms float64, units string) string {
+	unit, per := "/км", 1000.0
+	if units == unitsImperial { unit, per = "/mi", 1609.344 }
+	if ms < 0.1 { return "--:--" + unit }
+	s := int(per / ms)
+	if s >= 100*60 { return "--:--" + unit }
+	return fmt.Sprintf("%d:%02d%s", s/60, s%60, unit)
+}
+
+func fmtEle(m float64, units string) string {
+	if units == unitsImperial { return fmt.Sprintf("%.0f ft", m*3.28084) }
+	return fmt.Sprintf("%.0f м", m)
+}
@@ -0,0 +1,270 @@
+package tiles
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cacheMeta is the ".meta" sidecar of a cached tile: when it was fetched and
+// the validators for a conditional request once it goes stale.
+type cacheMeta struct {
+	Fetched      time.Time `json:"fetched"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Expires      time.Time `json:"expires,omitzero"` // from Cache-Control max-age; overrides the TTL
+	Empty        bool      `json:"empty,omitempty"`  // server answered 404/204: no tile here
+}
+
+// fresh reports whether a cached tile may be used without asking the server.
+// Tiles without max-age expire after ttl; ttl <= 0 keeps them forever.
+func (m cacheMeta) fresh(ttl time.Duration, now time.Time) bool {
+	if !m.Expires.IsZero() {
+		return now.Before(m.Expires)
+	}
+	if ttl <= 0 {
+		return true
+	}
+	return now.Before(m.Fetched.Add(ttl))
+}
+
+// metaFromResponse records validators and max-age of a 200 or 304 response;
+// validators missing from a 304 are kept from prev.
+func metaFromResponse(h http.Header, prev cacheMeta, now time.Time) cacheMeta {
+	m := cacheMeta{Fetched: now, ETag: h.Get("ETag"), LastModified: h.Get("Last-Modified")}
+	if m.ETag == "" {
+		m.ETag = prev.ETag
+	}
+	if m.LastModified == "" {
+		m.LastModified = prev.LastModified
+	}
+	for _, d := range strings.Split(h.Get("Cache-Control"), ",") {
+		d = strings.ToLower(strings.TrimSpace(d))
+		switch {
+		case d == "no-cache" || d == "no-store":
+			// the render still needs the tile; just revalidate it every time
+			m.Expires = now
+		case strings.HasPrefix(d, "max-age="):
+			if s, err := strconv.ParseInt(d[len("max-age="):], 10, 64); err == nil && m.Expires.IsZero() {
+				m.Expires = now.Add(time.Duration(s) * time.Second)
+			}
+		}
+	}
+	return m
+}
+
+func readMeta(cp string) cacheMeta {
+	var m cacheMeta
+	if b, err := os.ReadFile(cp + ".meta"); err == nil {
+		_ = json.Unmarshal(b, &m)
+	}
+	return m
+}
+
+func writeMeta(cp string, m cacheMeta) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cp+".meta", b, 0o644)
+}
+
+// touch marks a cache entry as recently used for LRU eviction.
+func touch(cp string) {
+	now := time.Now()
+	_ = os.Chtimes(cp, now, now)
+}
+
+// account adds the size change n of a rewritten cache entry (tile plus
+// sidecars) and evicts the least recently used tiles once MaxCacheBytes is
+// exceeded. The size is counted by a directory walk on first use and
+// tracked afterwards.
+func (f *Fetcher) account(n int64) {
+	if f.MaxCacheBytes <= 0 {
+		return
+	}
+	f.cacheMu.Lock()
+	defer f.cacheMu.Unlock()
+	if !f.cacheSized {
+		st, err := ReadCacheStats(f.CacheDir, f.TTL)
+		if err != nil {
+			return
+		}
+		f.cacheSize, f.cacheSized = st.Bytes, true
+	} else {
+		f.cacheSize += n
+	}
+	if f.cacheSize > f.MaxCacheBytes {
+		// evict a bit more than needed so every new tile doesn't trigger a walk
+		if _, _, err := PruneCache(f.CacheDir, f.MaxCacheBytes*9/10, 0); err == nil {
+			if st, err := ReadCacheStats(f.CacheDir, f.TTL); err == nil {
+				f.cacheSize = st.Bytes
+			}
+		}
+	}
+}
+
+// CacheStats summarizes a tile cache directory.
+type CacheStats struct {
+	Tiles          int
+	Bytes          int64 // tiles plus sidecars
+	Stale          int   // due for revalidation under the given TTL
+	Oldest, Newest time.Time
+}
+
+type cacheEntry struct {
+	path string // tile file; sidecars are path+".ct" and path+".meta"
+	size int64
+	used time.Time
+	meta cacheMeta
+}
+
+// cache files are <2 hex>/<2 hex>/<sha1 hex>.<ext>; nothing else is touched
+var cacheFileRe = regexp.MustCompile(`^[0-9a-f]{40}\.\w+$`)
+
+func cacheEntries(dir string) ([]cacheEntry, error) {
+	var es []cacheEntry
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && p == dir {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() || !cacheFileRe.MatchString(d.Name()) {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return nil // removed concurrently
+		}
+		es = append(es, cacheEntry{path: p, size: fi.Size() + sidecarSize(p), used: fi.ModTime(), meta: readMeta(p)})
+		return nil
+	})
+	return es, err
+}
+
+// ReadCacheStats walks dir and counts cached tiles.
+func ReadCacheStats(dir string, ttl time.Duration) (CacheStats, error) {
+	var st CacheStats
+	es, err := cacheEntries(dir)
+	if err != nil {
+		return st, err
+	}
+	now := time.Now()
+	for _, e := range es {
+		st.Tiles++
+		st.Bytes += e.size
+		if !e.meta.fresh(ttl, now) {
+			st.Stale++
+		}
+		if t := e.meta.Fetched; !t.IsZero() {
+			if st.Oldest.IsZero() || t.Before(st.Oldest) {
+				st.Oldest = t
+			}
+			if t.After(st.Newest) {
+				st.Newest = t
+			}
+		}
+	}
+	return st, nil
+}
+
+// PruneCache removes tiles that are stale under ttl (ttl <= 0 keeps them),
+// then evicts least recently used tiles until the cache fits into maxBytes
+// (maxBytes <= 0 means no limit).
+func PruneCache(dir string, maxBytes int64, ttl time.Duration) (removed int, freed int64, err error) {
+	es, err := cacheEntries(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	drop := func(e cacheEntry) {
+		if removeEntry(e.path) == nil {
+			removed++
+			freed += e.size
+		}
+	}
+
+	now := time.Now()
+	var keep []cacheEntry
+	var total int64
+	for _, e := range es {
+		if ttl > 0 && !e.meta.fresh(ttl, now) {
+			drop(e)
+			continue
+		}
+		keep = append(keep, e)
+		total += e.size
+	}
+	if maxBytes > 0 && total > maxBytes {
+		sort.Slice(keep, func(i, j int) bool { return keep[i].used.Before(keep[j].used) })
+		for _, e := range keep {
+			if total <= maxBytes {
+				break
+			}
+			drop(e)
+			total -= e.size
+		}
+	}
+	return removed, freed, nil
+}
+
+// ClearCache removes every cached tile with its sidecars and leftover
+// temporary files; other files in dir are left alone.
+func ClearCache(dir string) (removed int, err error) {
+	es, err := cacheEntries(dir)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range es {
+		if err := removeEntry(e.path); err != nil {
+			return removed, err
+		}
+		_ = os.Remove(e.path + ".tmp")
+		removed++
+	}
+	// now-empty shard directories
+	dirs, _ := filepath.Glob(filepath.Join(dir, "[0-9a-f][0-9a-f]", "[0-9a-f][0-9a-f]"))
+	for _, d := range dirs {
+		_ = os.Remove(d)
+		_ = os.Remove(filepath.Dir(d))
+	}
+	return removed, nil
+}
+
+func sidecarSize(p string) int64 {
+	var n int64
+	for _, side := range []string{".ct", ".meta"} {
+		if si, err := os.Stat(p + side); err == nil {
+			n += si.Size()
+		}
+	}
+	return n
+}
+
+// entrySize is the size of a cache entry on disk, 0 if there is none.
+func entrySize(p string) int64 {
+	fi, err := os.Stat(p)
+	if err != nil {
+		return 0
+	}
+	return fi.Size() + sidecarSize(p)
+}
+
+func removeEntry(p string) error {
+	_ = os.Remove(p + ".ct")
+	_ = os.Remove(p + ".meta")
+	err := os.Remove(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,126 @@
+package tiles
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAccountTracksDiskSize(t *testing.T) {
+	body := []byte("0123456789")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("ETag", `"v1"`)
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	f, err := NewFetcher(t.TempDir(), 1000, 10, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.MaxCacheBytes = 1 << 20
+	f.TTL = time.Nanosecond // every request goes to the server and rewrites the entry
+	ctx := context.Background()
+	check := func(when string) {
+		t.Helper()
+		st, err := ReadCacheStats(f.CacheDir, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.cacheSize != st.Bytes {
+			t.Errorf("%s: tracked %d bytes, %d on disk", when, f.cacheSize, st.Bytes)
+		}
+	}
+	for _, u := range []string{"/1/0/0.png", "/1/0/1.png", "/1/0/0.png", "/1/0/0.png"} {
+		if _, _, err := f.GetTile(ctx, srv.URL+u, nil); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+		check(u)
+	}
+}
+
+func TestCacheMetaFresh(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name string
+		m    cacheMeta
+		ttl  time.Duration
+		want bool
+	}{
+		{"within ttl", cacheMeta{Fetched: now.Add(-time.Hour)}, 2 * time.Hour, true},
+		{"past ttl", cacheMeta{Fetched: now.Add(-3 * time.Hour)}, 2 * time.Hour, false},
+		{"ttl 0 keeps forever", cacheMeta{Fetched: now.Add(-1000 * time.Hour)}, 0, true},
+		{"max-age overrides longer ttl", cacheMeta{Fetched: now.Add(-time.Hour), Expires: now.Add(-time.Minute)}, 24 * time.Hour, false},
+		{"max-age overrides shorter ttl", cacheMeta{Fetched: now.Add(-time.Hour), Expires: now.Add(time.Minute)}, time.Minute, true},
+		{"max-age overrides ttl 0", cacheMeta{Fetched: now.Add(-time.Hour), Expires: now.Add(-time.Second)}, 0, false},
+		{"expires now", cacheMeta{Fetched: now, Expires: now}, time.Hour, false},
+	} {
+		if got := tc.m.fresh(tc.ttl, now); got != tc.want {
+			t.Errorf("%s: fresh = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestMetaFromResponse(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	prev := cacheMeta{Fetched: now.Add(-time.Hour), ETag: `"old"`, LastModified: "Mon, 02 Jan 2006 15:04:05 GMT"}
+	for _, tc := range []struct {
+		name string
+		h    http.Header
+		prev cacheMeta
+		want cacheMeta
+	}{
+		{
+			"no headers",
+			http.Header{}, cacheMeta{},
+			cacheMeta{Fetched: now},
+		},
+		{
+			"validators and max-age",
+			http.Header{"Etag": {`"v2"`}, "Last-Modified": {"Tue, 03 Jan 2006 15:04:05 GMT"}, "Cache-Control": {"public, max-age=600"}},
+			prev,
+			cacheMeta{Fetched: now, ETag: `"v2"`, LastModified: "Tue, 03 Jan 2006 15:04:05 GMT", Expires: now.Add(10 * time.Minute)},
+		},
+		{
+			"304 without validators keeps prev",
+			http.Header{"Cache-Control": {"max-age=60"}},
+			prev,
+			cacheMeta{Fetched: now, ETag: `"old"`, LastModified: prev.LastModified, Expires: now.Add(time.Minute)},
+		},
+		{
+			"304 with new etag",
+			http.Header{"Etag": {`"v3"`}},
+			prev,
+			cacheMeta{Fetched: now, ETag: `"v3"`, LastModified: prev.LastModified},
+		},
+		{
+			"no-cache expires at once",
+			http.Header{"Cache-Control": {"no-cache"}},
+			cacheMeta{},
+			cacheMeta{Fetched: now, Expires: now},
+		},
+		{
+			"no-store wins over later max-age",
+			http.Header{"Cache-Control": {"No-Store, max-age=600"}},
+			cacheMeta{},
+			cacheMeta{Fetched: now, Expires: now},
+		},
+		{
+			"bad max-age ignored",
+			http.Header{"Cache-Control": {"max-age=soon"}},
+			cacheMeta{},
+			cacheMeta{Fetched: now},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := metaFromResponse(tc.h, tc.prev, now)
+			if got != tc.want {
+				t.Errorf("got %+v\nwant %+v", got, tc.want)
+			}
+		})
+	}
+}
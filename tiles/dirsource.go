@@ -0,0 +1,86 @@
+package tiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DirSource reads tiles from a plain directory tree such as
+// /data/osm/{z}/{x}/{y}.png, the layout written by most tile downloaders.
+// The template accepts the same placeholders as URL templates.
+type DirSource struct {
+	Preset Preset
+}
+
+// NewDirSource checks the path template. The zoom range is taken from the
+// numeric directories at the {z} level when the template has one, else 0..22.
+func NewDirSource(tmpl string) (*DirSource, error) {
+	p := Preset{
+		Name:    filepath.Base(filepath.Clean(strings.SplitN(tmpl, "{", 2)[0])),
+		URLTmpl: tmpl,
+		MinZoom: 0,
+		MaxZoom: 22,
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	if zmin, zmax, ok := dirZooms(tmpl); ok {
+		p.MinZoom, p.MaxZoom = zmin, zmax
+	}
+	return &DirSource{Preset: p}, nil
+}
+
+// dirZooms lists the parent of a "{z}" path element for zoom directories.
+func dirZooms(tmpl string) (int, int, bool) {
+	i := strings.Index(tmpl, "{z}")
+	if i < 0 || !strings.HasPrefix(tmpl[i+3:], string(filepath.Separator)) {
+		return 0, 0, false
+	}
+	root := tmpl[:i]
+	if root != "" && !strings.HasSuffix(root, string(filepath.Separator)) {
+		return 0, 0, false
+	}
+	if root == "" {
+		root = "."
+	}
+	des, err := os.ReadDir(root)
+	if err != nil {
+		return 0, 0, false
+	}
+	zmin, zmax, found := 0, 0, false
+	for _, de := range des {
+		z, err := strconv.Atoi(de.Name())
+		if !de.IsDir() || err != nil || z < 0 || z > 30 {
+			continue
+		}
+		if !found || z < zmin {
+			zmin = z
+		}
+		if !found || z > zmax {
+			zmax = z
+		}
+		found = true
+	}
+	return zmin, zmax, found
+}
+
+func (d *DirSource) Tile(_ context.Context, z, x, y int) ([]byte, error) {
+	path, err := d.Preset.FillURL(z, x, y)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("tiles dir: %s: %w", path, ErrNoTile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tiles dir: %w", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,82 @@
+package tiles
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTileFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDirSourcePlaceholders(t *testing.T) {
+	root := t.TempDir()
+	writeTileFile(t, filepath.Join(root, "xyz", "3", "5", "2.png"), "xyz")
+	writeTileFile(t, filepath.Join(root, "tms", "3", "5", "5.png"), "tms") // 8-1-2
+	writeTileFile(t, filepath.Join(root, "quad", Quadkey(3, 5, 2)+".png"), "quad")
+	ctx := context.Background()
+	for _, tc := range []struct{ tmpl, want string }{
+		{filepath.Join(root, "xyz", "{z}", "{x}", "{y}.png"), "xyz"},
+		{filepath.Join(root, "tms", "{z}", "{x}", "{-y}.png"), "tms"},
+		{filepath.Join(root, "quad", "{q}.png"), "quad"},
+	} {
+		d, err := NewDirSource(tc.tmpl)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.tmpl, err)
+		}
+		got, err := d.Tile(ctx, 3, 5, 2)
+		if err != nil || string(got) != tc.want {
+			t.Errorf("%s: %q, %v; want %q", tc.tmpl, got, err, tc.want)
+		}
+		if _, err := d.Tile(ctx, 3, 5, 3); !errors.Is(err, ErrNoTile) {
+			t.Errorf("%s: missing file: %v, want ErrNoTile", tc.tmpl, err)
+		}
+	}
+	for _, tmpl := range []string{filepath.Join(root, "{z}", "{x}.png"), filepath.Join(root, "{z}", "{x}", "{y}{w}.png")} {
+		if _, err := NewDirSource(tmpl); err == nil {
+			t.Errorf("%s: no error", tmpl)
+		}
+	}
+}
+
+func TestDirSourceZoomRange(t *testing.T) {
+	root := t.TempDir()
+	for _, z := range []string{"12", "8", "15"} {
+		if err := os.MkdirAll(filepath.Join(root, z), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// not zoom levels: a file, a non-numeric and an out-of-range directory
+	writeTileFile(t, filepath.Join(root, "3"), "")
+	for _, d := range []string{"thumbs", "99"} {
+		if err := os.MkdirAll(filepath.Join(root, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tc := range []struct {
+		tmpl       string
+		minZ, maxZ int
+	}{
+		{filepath.Join(root, "{z}", "{x}", "{y}.png"), 8, 15},
+		{filepath.Join(root, "t{z}", "{x}", "{y}.png"), 0, 22}, // {z} is not a whole path element
+		{filepath.Join(root, "{x}", "{z}-{y}.png"), 0, 22},
+		{filepath.Join(root, "missing", "{z}", "{x}", "{y}.png"), 0, 22},
+	} {
+		d, err := NewDirSource(tc.tmpl)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.tmpl, err)
+		}
+		if d.Preset.MinZoom != tc.minZ || d.Preset.MaxZoom != tc.maxZ {
+			t.Errorf("%s: z%d-%d, want z%d-%d", tc.tmpl, d.Preset.MinZoom, d.Preset.MaxZoom, tc.minZ, tc.maxZ)
+		}
+	}
+}
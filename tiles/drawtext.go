@@ -1,114 +1,118 @@
 package tiles
 
 import (
+	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
+	"os"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
 )
 
-// Мини-шрифт 3x5 (моноширинный). Каждый байт — 1 бит (старший слева).
-// Поддержаны ASCII 32..95 (пробел, !, .., _, A..Z, [, \, ], ^, _), и a..z, 0..9, общие знаки.
-// Это не красота, но годится для атрибуции мелким шрифтом без зависимостей.
-var miniFont = map[rune][5]byte{
-	' ': {0x00, 0x00, 0x00, 0x00, 0x00},
-	'.': {0x00, 0x00, 0x00, 0x40, 0x00},
-	',': {0x00, 0x00, 0x00, 0x40, 0x80},
-	':': {0x00, 0x40, 0x00, 0x40, 0x00},
-	'-': {0x00, 0x00, 0xE0, 0x00, 0x00},
-	'_': {0x00, 0x00, 0x00, 0x00, 0xE0},
-	'/': {0x20, 0x20, 0x40, 0x80, 0x80},
-	'\\': {0x80, 0x80, 0x40, 0x20, 0x20},
-	'(': {0x20, 0x40, 0x40, 0x40, 0x20},
-	')': {0x80, 0x40, 0x40, 0x40, 0x80},
-	'[': {0xE0, 0x80, 0x80, 0x80, 0xE0},
-	']': {0xE0, 0x20, 0x20, 0x20, 0xE0},
-	'\'': {0x80, 0x80, 0x00, 0x00, 0x00},
-	'"': {0xA0, 0xA0, 0x00, 0x00, 0x00},
-	'!': {0x80, 0x80, 0x80, 0x00, 0x80},
-	'?': {0xE0, 0x20, 0x40, 0x00, 0x40},
-	'+': {0x00, 0x40, 0xE0, 0x40, 0x00},
-	'@': {0xE0, 0xA0, 0xE0, 0x80, 0xE0},
-	'#': {0xA0, 0xE0, 0xA0, 0xE0, 0xA0},
-	'%': {0xA0, 0x20, 0x40, 0x80, 0xA0},
-	'&': {0x40, 0x80, 0x60, 0xA0, 0x60},
-	'*': {0x00, 0xA0, 0x40, 0xA0, 0x00},
-	'=': {0x00, 0xE0, 0x00, 0xE0, 0x00},
+// Текст рисуется TrueType-шрифтом через golang.org/x/image/font.
+// По умолчанию — встроенный Go Regular (BSD, есть кириллица), можно подменить своим TTF/OTF.
 
-	'0': {0xE0, 0xA0, 0xA0, 0xA0, 0xE0},
-	'1': {0x40, 0xC0, 0x40, 0x40, 0xE0},
-	'2': {0xE0, 0x20, 0xE0, 0x80, 0xE0},
-	'3': {0xE0, 0x20, 0x60, 0x20, 0xE0},
-	'4': {0xA0, 0xA0, 0xE0, 0x20, 0x20},
-	'5': {0xE0, 0x80, 0xE0, 0x20, 0xE0},
-	'6': {0x60, 0x80, 0xE0, 0xA0, 0xE0},
-	'7': {0xE0, 0x20, 0x40, 0x40, 0x40},
-	'8': {0xE0, 0xA0, 0xE0, 0xA0, 0xE0},
-	'9': {0xE0, 0xA0, 0xE0, 0x20, 0x60},
+// TextStyle — как рисовать строку: шрифт, цвет, обводка и плашка под текстом.
+type TextStyle struct {
+	Face         font.Face
+	Color        color.Color
+	Outline      color.Color // nil — без обводки
+	OutlineWidth int
+	Panel        color.Color // nil — без плашки
+	Padding      int
+}
 
-	'A': {0x40, 0xA0, 0xE0, 0xA0, 0xA0},
-	'B': {0xC0, 0xA0, 0xC0, 0xA0, 0xC0},
-	'C': {0x60, 0x80, 0x80, 0x80, 0x60},
-	'D': {0xC0, 0xA0, 0xA0, 0xA0, 0xC0},
-	'E': {0xE0, 0x80, 0xE0, 0x80, 0xE0},
-	'F': {0xE0, 0x80, 0xE0, 0x80, 0x80},
-	'G': {0x60, 0x80, 0xA0, 0xA0, 0x60},
-	'H': {0xA0, 0xA0, 0xE0, 0xA0, 0xA0},
-	'I': {0xE0, 0x40, 0x40, 0x40, 0xE0},
-	'J': {0x20, 0x20, 0x20, 0xA0, 0x40},
-	'K': {0xA0, 0xA0, 0xC0, 0xA0, 0xA0},
-	'L': {0x80, 0x80, 0x80, 0x80, 0xE0},
-	'M': {0xA0, 0xE0, 0xE0, 0xA0, 0xA0},
-	'N': {0xA0, 0xE0, 0xE0, 0xE0, 0xA0},
-	'O': {0x40, 0xA0, 0xA0, 0xA0, 0x40},
-	'P': {0xC0, 0xA0, 0xC0, 0x80, 0x80},
-	'Q': {0x40, 0xA0, 0xA0, 0xE0, 0x60},
-	'R': {0xC0, 0xA0, 0xC0, 0xA0, 0xA0},
-	'S': {0x60, 0x80, 0x60, 0x20, 0xC0},
-	'T': {0xE0, 0x40, 0x40, 0x40, 0x40},
-	'U': {0xA0, 0xA0, 0xA0, 0xA0, 0xE0},
-	'V': {0xA0, 0xA0, 0xA0, 0xA0, 0x40},
-	'W': {0xA0, 0xA0, 0xE0, 0xE0, 0xA0},
-	'X': {0xA0, 0xA0, 0x40, 0xA0, 0xA0},
-	'Y': {0xA0, 0xA0, 0x40, 0x40, 0x40},
-	'Z': {0xE0, 0x20, 0x40, 0x80, 0xE0},
+// LoadFace загружает шрифт из файла; пустой path — встроенный Go Regular.
+func LoadFace(path string, size float64) (font.Face, error) {
+	data := goregular.TTF
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("font: %w", err)
+		}
+		data = b
+	}
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("font %s: %w", path, err)
+	}
+	if size <= 0 {
+		size = 12
+	}
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
 
-	'a': {0x00, 0x60, 0x20, 0xA0, 0xE0},
-	'b': {0x80, 0xC0, 0xA0, 0xA0, 0xC0},
-	'c': {0x00, 0x60, 0x80, 0x80, 0x60},
-	'd': {0x20, 0x60, 0xA0, 0xA0, 0x60},
-	'e': {0x00, 0x60, 0xA0, 0xC0, 0x60},
-	'f': {0x20, 0x40, 0xE0, 0x40, 0x40},
-	'g': {0x00, 0x60, 0xA0, 0x60, 0x20},
-	'h': {0x80, 0xC0, 0xA0, 0xA0, 0xA0},
-	'i': {0x40, 0x00, 0x40, 0x40, 0xE0},
-	'j': {0x20, 0x00, 0x20, 0xA0, 0x40},
-	'k': {0x80, 0xA0, 0xC0, 0xA0, 0xA0},
-	'l': {0x40, 0x40, 0x40, 0x40, 0x20},
-	'm': {0x00, 0xE0, 0xE0, 0xA0, 0xA0},
-	'n': {0x00, 0xC0, 0xA0, 0xA0, 0xA0},
-	'o': {0x00, 0x40, 0xA0, 0xA0, 0x40},
-	'p': {0x00, 0xC0, 0xA0, 0xC0, 0x80},
-	'q': {0x00, 0x60, 0xA0, 0x60, 0x20},
-	'r': {0x00, 0x60, 0x80, 0x80, 0x80},
-	's': {0x00, 0x60, 0x40, 0x20, 0xC0},
-	't': {0x40, 0xE0, 0x40, 0x40, 0x20},
-	'u': {0x00, 0xA0, 0xA0, 0xA0, 0x60},
-	'v': {0x00, 0xA0, 0xA0, 0xA0, 0x40},
-	'w': {0x00, 0xA0, 0xE0, 0xE0, 0x40},
-	'x': {0x00, 0xA0, 0x40, 0xA0, 0xA0},
-	'y': {0x00, 0xA0, 0xA0, 0x60, 0x20},
-	'z': {0x00, 0xE0, 0x20, 0x40, 0xE0},
+// DefaultTextStyle — белый текст с тёмной обводкой на полупрозрачной плашке.
+func DefaultTextStyle(face font.Face) TextStyle {
+	return TextStyle{
+		Face:         face,
+		Color:        color.RGBA{255, 255, 255, 230},
+		Outline:      color.RGBA{0, 0, 0, 200},
+		OutlineWidth: 1,
+		Panel:        color.RGBA{0, 0, 0, 120},
+		Padding:      4,
+	}
 }
 
-func DrawAttribution(img *image.RGBA, text string) {
+// MeasureText — размер строки (без плашки) в пикселях.
+func MeasureText(face font.Face, s string) (w, h int) {
+	m := face.Metrics()
+	w = font.MeasureString(face, s).Ceil()
+	h = (m.Ascent + m.Descent).Ceil()
+	return
+}
+
+// BoxSize — размер строки вместе с плашкой и обводкой.
+func (st TextStyle) BoxSize(s string) (w, h int) {
+	w, h = MeasureText(st.Face, s)
+	pad := st.Padding + st.OutlineWidth
+	return w + 2*pad, h + 2*pad
+}
+
+// DrawText рисует строку, (x,y) — левый верхний угол плашки. Возвращает занятый прямоугольник.
+func DrawText(dst *image.RGBA, x, y int, s string, st TextStyle) image.Rectangle {
+	w, h := st.BoxSize(s)
+	box := image.Rect(x, y, x+w, y+h)
+	if s == "" || st.Face == nil {
+		return box
+	}
+	if st.Panel != nil {
+		draw.Draw(dst, box, image.NewUniform(st.Panel), image.Point{}, draw.Over)
+	}
+	pad := st.Padding + st.OutlineWidth
+	base := fixed.P(x+pad, y+pad+st.Face.Metrics().Ascent.Ceil())
+
+	d := &font.Drawer{Dst: dst, Face: st.Face}
+	if st.Outline != nil && st.OutlineWidth > 0 {
+		d.Src = image.NewUniform(st.Outline)
+		ow := st.OutlineWidth
+		for dy := -ow; dy <= ow; dy++ {
+			for dx := -ow; dx <= ow; dx++ {
+				if dx == 0 && dy == 0 {
+					continue
+				}
+				d.Dot = base.Add(fixed.P(dx, dy))
+				d.DrawString(s)
+			}
+		}
+	}
+	d.Src = image.NewUniform(st.Color)
+	d.Dot = base
+	d.DrawString(s)
+	return box
+}
+
+// DrawAttribution рисует подпись источника карты в правом нижнем углу.
+func DrawAttribution(img *image.RGBA, text string, st TextStyle) {
 	if text == "" {
 		return
 	}
 	b := img.Bounds()
-	padding := 6
-	// estimate width: 4 px per char (3 glyph + 1 space)
-	w := len([]rune(text))*4 + padding*2
-	h := 5 + padding*2
+	w, h := st.BoxSize(text)
 
 	x := b.Max.X - w - 6
 	y := b.Max.Y - h - 6
@@ -118,29 +122,5 @@ func DrawAttribution(img *image.RGBA, text string) {
 	if y < 0 {
 		y = 0
 	}
-	// bg panel (semi-transparent black)
-	bg := image.NewUniform(color.RGBA{0, 0, 0, 120})
-	draw.Draw(img, image.Rect(x, y, x+w, y+h), bg, image.Point{}, draw.Over)
-
-	fg := color.RGBA{255, 255, 255, 220}
-	drawMiniText(img, x+padding, y+padding, text, fg)
-}
-
-func drawMiniText(dst *image.RGBA, x, y int, s string, col color.Color) {
-	for _, r := range []rune(s) {
-		g, ok := miniFont[r]
-		if !ok {
-			g = miniFont['?']
-		}
-		// 3 columns (bits 7..5), 5 rows
-		for row := 0; row < 5; row++ {
-			line := g[row]
-			for colBit := 0; colBit < 3; colBit++ {
-				if (line & (0x80 >> colBit)) != 0 {
-					dst.Set(x+colBit, y+row, col)
-				}
-			}
-		}
-		x += 4 // 3px glyph + 1px space
-	}
+	DrawText(img, x, y, text, st)
 }
@@ -0,0 +1,127 @@
+package tiles
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"sync"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// maxOverzoom is how many zoom levels up a missing tile is looked for;
+// beyond 5 the parent quadrant is under 8 px and no better than a placeholder.
+const maxOverzoom = 5
+
+// PlaceholderColor fills tiles that are missing with no parent to upscale.
+var PlaceholderColor = color.RGBA{0xdd, 0xdd, 0xd8, 0xff}
+
+// Fallback wraps a Source so a missing, failed or undecodable tile degrades
+// instead of aborting the render: the matching quadrant of a parent tile is
+// upscaled (overzoom), else a neutral placeholder is served. Access errors
+// and cancellation are still returned.
+type Fallback struct {
+	Src         Source
+	Placeholder color.Color // PlaceholderColor by default; transparent suits overlay layers
+
+	mu       sync.Mutex
+	degraded map[tileXY]bool // true: overzoomed, false: placeholder
+}
+
+func NewFallback(src Source) *Fallback {
+	return &Fallback{Src: src, Placeholder: PlaceholderColor, degraded: map[tileXY]bool{}}
+}
+
+func (f *Fallback) Tile(ctx context.Context, z, x, y int) ([]byte, error) {
+	data, img, err := f.tile(ctx, tileXY{z, x, y})
+	if err != nil || data != nil {
+		return data, err
+	}
+	var buf bytes.Buffer
+	enc := png.Encoder{CompressionLevel: png.BestSpeed}
+	if err := enc.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// image serves BuildMosaic the decoded tile without a PNG round trip.
+func (f *Fallback) image(ctx context.Context, t tileXY) (image.Image, error) {
+	_, img, err := f.tile(ctx, t)
+	return img, err
+}
+
+// tile returns the bytes and decoded image of a usable tile, or only a
+// stand-in image for a degraded one.
+func (f *Fallback) tile(ctx context.Context, t tileXY) ([]byte, image.Image, error) {
+	data, err := f.Src.Tile(ctx, t.z, t.x, t.y)
+	if err == nil {
+		img, _, derr := decodeTile(data)
+		if derr == nil {
+			f.mu.Lock()
+			delete(f.degraded, t) // a retry during a later frame may succeed
+			f.mu.Unlock()
+			return data, fitTile(img), nil
+		}
+		// a 200 with an HTML error page, a truncated cache file
+		err = derr
+	}
+	if ctx.Err() != nil || errors.Is(err, ErrAccessDenied) {
+		return nil, nil, err
+	}
+
+	img, over := f.overzoom(ctx, t.z, t.x, t.y)
+	if img == nil {
+		p := image.NewRGBA(image.Rect(0, 0, TileSize, TileSize))
+		draw.Draw(p, p.Bounds(), &image.Uniform{f.Placeholder}, image.Point{}, draw.Src)
+		img = p
+	}
+	f.mu.Lock()
+	f.degraded[t] = over
+	f.mu.Unlock()
+	return nil, img, nil
+}
+
+// overzoom crops the quadrant of the nearest available ancestor and scales
+// it up to a full tile.
+func (f *Fallback) overzoom(ctx context.Context, z, x, y int) (image.Image, bool) {
+	for d := 1; d <= maxOverzoom && z-d >= 0; d++ {
+		data, err := cachedTile(ctx, f.Src, z-d, x>>d, y>>d)
+		if err != nil {
+			continue
+		}
+		parent, _, err := decodeTile(data)
+		if err != nil {
+			continue
+		}
+		parent = fitTile(parent)
+		n := TileSize >> d
+		mask := 1<<d - 1
+		sub := image.Rect((x&mask)*n, (y&mask)*n, (x&mask+1)*n, (y&mask+1)*n).Add(parent.Bounds().Min)
+		out := image.NewRGBA(image.Rect(0, 0, TileSize, TileSize))
+		xdraw.CatmullRom.Scale(out, out.Bounds(), parent, sub, xdraw.Src, nil)
+		return out, true
+	}
+	return nil, false
+}
+
+// Degraded returns how many distinct tiles were overzoomed from a parent
+// and how many were replaced by a placeholder.
+func (f *Fallback) Degraded() (overzoomed, placeholders int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, over := range f.degraded {
+		if over {
+			overzoomed++
+		} else {
+			placeholders++
+		}
+	}
+	return overzoomed, placeholders
+}
+
+func (f *Fallback) workers() int { return workersFor(f.Src) }
@@ -0,0 +1,197 @@
+package tiles
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"sync"
+	"testing"
+)
+
+// mapSource serves fixed tile bytes; absent tiles are ErrNoTile unless errs
+// names another error for them.
+type mapSource struct {
+	mu    sync.Mutex
+	tiles map[tileXY][]byte
+	errs  map[tileXY]error
+}
+
+func (s *mapSource) Tile(_ context.Context, z, x, y int) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := tileXY{z, x, y}
+	if err := s.errs[t]; err != nil {
+		return nil, err
+	}
+	if b, ok := s.tiles[t]; ok {
+		return b, nil
+	}
+	return nil, ErrNoTile
+}
+
+// cellColor is the color of cell (cx, cy) of the 4×4 test grid.
+func cellColor(cx, cy int) color.RGBA {
+	return color.RGBA{uint8(cx * 60), uint8(cy * 60), 200, 255}
+}
+
+// gridTile is a tile split into 4×4 uniformly colored 64 px cells.
+func gridTile(t *testing.T) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, TileSize, TileSize))
+	n := TileSize / 4
+	for y := 0; y < TileSize; y++ {
+		for x := 0; x < TileSize; x++ {
+			img.SetRGBA(x, y, cellColor(x/n, y/n))
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decodedAt(t *testing.T, f *Fallback, z, x, y int) image.Image {
+	t.Helper()
+	data, err := f.Tile(context.Background(), z, x, y)
+	if err != nil {
+		t.Fatalf("%d/%d/%d: %v", z, x, y, err)
+	}
+	img, _, err := decodeTile(data)
+	if err != nil {
+		t.Fatalf("%d/%d/%d: fallback served undecodable bytes: %v", z, x, y, err)
+	}
+	return img
+}
+
+func rgbaAt(img image.Image, x, y int) color.RGBA {
+	return color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+}
+
+func TestFallbackOverzoomQuadrant(t *testing.T) {
+	src := &mapSource{tiles: map[tileXY][]byte{{1, 0, 0}: gridTile(t)}}
+	f := NewFallback(src)
+
+	// d=1: 2/1/0 is the top-right quarter of 1/0/0, cells (2..3, 0..1)
+	img := decodedAt(t, f, 2, 1, 0)
+	for _, tc := range []struct{ x, y, cx, cy int }{
+		{32, 32, 2, 0},
+		{200, 32, 3, 0},
+		{32, 200, 2, 1},
+		{200, 200, 3, 1},
+	} {
+		if got, want := rgbaAt(img, tc.x, tc.y), cellColor(tc.cx, tc.cy); got != want {
+			t.Errorf("d=1 pixel (%d,%d) = %v, want cell (%d,%d) %v", tc.x, tc.y, got, tc.cx, tc.cy, want)
+		}
+	}
+
+	// d=2: 3/3/1 is the single cell (3, 1) of 1/0/0
+	img = decodedAt(t, f, 3, 3, 1)
+	for _, p := range []image.Point{{10, 10}, {128, 128}, {245, 245}} {
+		if got, want := rgbaAt(img, p.X, p.Y), cellColor(3, 1); got != want {
+			t.Errorf("d=2 pixel %v = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestFallbackPlaceholder(t *testing.T) {
+	f := NewFallback(&mapSource{})
+	if got := rgbaAt(decodedAt(t, f, 1, 1, 1), 100, 100); got != PlaceholderColor {
+		t.Errorf("placeholder %v, want %v", got, PlaceholderColor)
+	}
+
+	f = NewFallback(&mapSource{})
+	f.Placeholder = color.Transparent
+	if got := rgbaAt(decodedAt(t, f, 1, 1, 1), 100, 100); got.A != 0 {
+		t.Errorf("transparent placeholder %v", got)
+	}
+
+	// beyond maxOverzoom the parent is not used
+	src := &mapSource{tiles: map[tileXY][]byte{{0, 0, 0}: gridTile(t)}}
+	f = NewFallback(src)
+	if got := rgbaAt(decodedAt(t, f, maxOverzoom+1, 0, 0), 1, 1); got != PlaceholderColor {
+		t.Errorf("z=%d: %v, want the placeholder", maxOverzoom+1, got)
+	}
+}
+
+func TestFallbackUndecodable(t *testing.T) {
+	src := &mapSource{tiles: map[tileXY][]byte{
+		{1, 0, 0}: gridTile(t),
+		{2, 0, 0}: []byte("<html>rate limited</html>"),
+		{2, 1, 1}: gridTile(t)[:100], // truncated cache file
+	}}
+	f := NewFallback(src)
+	for _, c := range [][2]int{{0, 0}, {1, 1}} {
+		img := decodedAt(t, f, 2, c[0], c[1])
+		if got, want := rgbaAt(img, 32, 32), cellColor(2*c[0], 2*c[1]); got != want {
+			t.Errorf("2/%d/%d: %v, want overzoomed %v", c[0], c[1], got, want)
+		}
+	}
+
+	// BuildMosaic takes decoded images directly
+	if _, err := tileImage(context.Background(), f, tileXY{2, 0, 0}); err != nil {
+		t.Errorf("tileImage: %v", err)
+	}
+	if _, err := tileImage(context.Background(), src, tileXY{2, 0, 0}); err == nil {
+		t.Error("tileImage without a Fallback decoded an HTML page")
+	}
+}
+
+func TestFallbackPassesErrors(t *testing.T) {
+	denied := fmt.Errorf("tile HTTP 403: %w", ErrAccessDenied)
+	src := &mapSource{
+		tiles: map[tileXY][]byte{{0, 0, 0}: gridTile(t)},
+		errs:  map[tileXY]error{{1, 0, 0}: denied},
+	}
+	f := NewFallback(src)
+	if _, err := f.Tile(context.Background(), 1, 0, 0); err != denied {
+		t.Errorf("access error: %v, want %v", err, denied)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	src.errs[tileXY{1, 1, 0}] = ctx.Err()
+	if _, err := f.Tile(ctx, 1, 1, 0); err != context.Canceled {
+		t.Errorf("canceled: %v", err)
+	}
+	if o, p := f.Degraded(); o+p != 0 {
+		t.Errorf("errors counted as degraded: %d, %d", o, p)
+	}
+}
+
+func TestFallbackDegraded(t *testing.T) {
+	src := &mapSource{tiles: map[tileXY][]byte{
+		{1, 0, 0}: gridTile(t),
+		{1, 1, 0}: gridTile(t),
+	}}
+	f := NewFallback(src)
+	ctx := context.Background()
+	for _, tt := range []tileXY{
+		{1, 0, 0}, // present
+		{2, 0, 0}, // overzoomed
+		{2, 1, 1}, // overzoomed
+		{2, 1, 1}, // same tile again: counted once
+		{0, 0, 0}, // no parent: placeholder
+		{1, 0, 1}, // parent 0/0/0 missing too: placeholder
+	} {
+		if _, err := f.Tile(ctx, tt.z, tt.x, tt.y); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if o, p := f.Degraded(); o != 2 || p != 2 {
+		t.Errorf("Degraded() = %d, %d; want 2, 2", o, p)
+	}
+
+	// the tile shows up on a later frame: no longer degraded
+	src.mu.Lock()
+	src.tiles[tileXY{2, 0, 0}] = gridTile(t)
+	src.mu.Unlock()
+	if _, err := f.Tile(ctx, 2, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if o, p := f.Degraded(); o != 1 || p != 2 {
+		t.Errorf("after recovery Degraded() = %d, %d; want 1, 2", o, p)
+	}
+}
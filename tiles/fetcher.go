@@ -11,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/time/rate"
@@ -22,8 +23,32 @@ type Fetcher struct {
 	CacheDir   string
 	UserAgent  string
 	MaxRetries int
+	Workers    int  // concurrent tile requests in BuildMosaic/Prefetch
+	Offline    bool // serve from CacheDir only, never touch the network
+
+	// TTL is how long a cached tile is used without revalidation when the
+	// server sent no max-age; <= 0 keeps tiles forever.
+	TTL time.Duration
+	// MaxCacheBytes caps CacheDir; least recently used tiles are evicted
+	// beyond it. <= 0 means no limit.
+	MaxCacheBytes int64
+
+	cacheMu    sync.Mutex
+	cacheSize  int64
+	cacheSized bool
+
+	pauseMu    sync.Mutex
+	pauseUntil time.Time // set by 429/503 Retry-After
 }
 
+// ErrOffline marks a cache miss in offline mode. Such errors also match
+// ErrNoTile, so callers may treat the tile as absent.
+var ErrOffline = errors.New("offline")
+
+// ErrAccessDenied marks 401/403/407 answers: a key or policy problem that
+// no retry or fallback will fix.
+var ErrAccessDenied = errors.New("access denied")
+
 func NewFetcher(cacheDir string, rps float64, burst int, timeout time.Duration) (*Fetcher, error) {
 	if cacheDir == "" {
 		cacheDir = ".tile-cache"
@@ -39,10 +64,13 @@ func NewFetcher(cacheDir string, rps float64, burst int, timeout time.Duration)
 		CacheDir:   cacheDir,
 		UserAgent:  "gpx2gif/1.0 (+tiles; https://openstreetmap.org)",
 		MaxRetries: 3,
+		Workers:    DefaultWorkers,
 	}, nil
 }
 
 func (f *Fetcher) cachePath(u string) string {
+	// keys are not part of the tile identity: rotating a key keeps the cache
+	u = Redact(u)
 	sum := sha1.Sum([]byte(u))
 	hexid := hex.EncodeToString(sum[:])
 	ext := ".tile"
@@ -70,10 +98,54 @@ func (f *Fetcher) readFromCache(cp string) ([]byte, string, error) {
 	return b, ct, nil
 }
 
-func (f *Fetcher) GetTile(ctx context.Context, url string, headers map[string]string) ([]byte, string, error) {
+// cached returns the cached copy of url regardless of its age, without
+// touching the network; used for overzoom fallback.
+func (f *Fetcher) cached(url string) ([]byte, error) {
 	cp := f.cachePath(url)
-	if b, ct, err := f.readFromCache(cp); err == nil {
-		return b, ct, nil
+	if readMeta(cp).Empty {
+		return nil, ErrNoTile
+	}
+	b, _, err := f.readFromCache(cp)
+	return b, err
+}
+
+func (f *Fetcher) GetTile(ctx context.Context, url string, headers map[string]string) ([]byte, string, error) {
+	return f.get(ctx, url, url, headers, http.StatusOK)
+}
+
+// GetRange fetches n bytes at offset off with an HTTP Range request.
+// Ranges are cached like tiles, keyed by URL and byte range.
+func (f *Fetcher) GetRange(ctx context.Context, url string, headers map[string]string, off, n uint64) ([]byte, error) {
+	rng := fmt.Sprintf("bytes=%d-%d", off, off+n-1)
+	h := make(map[string]string, len(headers)+1)
+	for k, v := range headers {
+		h[k] = v
+	}
+	h["Range"] = rng
+	b, _, err := f.get(ctx, url+"#"+rng, url, h, http.StatusPartialContent)
+	if err == nil && uint64(len(b)) != n {
+		err = fmt.Errorf("range %s of %s: got %d bytes", rng, Redact(url), len(b))
+	}
+	return b, err
+}
+
+// get downloads url (cached under key) expecting the want status code.
+// A cached copy is used while fresh, then revalidated with If-None-Match /
+// If-Modified-Since; if revalidation fails the stale copy is served.
+// 404/204/410 are cached as an empty tile and reported as ErrNoTile.
+func (f *Fetcher) get(ctx context.Context, key, url string, headers map[string]string, want int) ([]byte, string, error) {
+	cp := f.cachePath(key)
+	cached, cachedCT, cerr := f.readFromCache(cp)
+	meta := readMeta(cp)
+	if cerr == nil && (f.Offline || meta.fresh(f.TTL, time.Now())) {
+		touch(cp)
+		if meta.Empty {
+			return nil, "", fmt.Errorf("%s: %w (cached empty response)", Redact(url), ErrNoTile)
+		}
+		return cached, cachedCT, nil
+	}
+	if f.Offline {
+		return nil, "", fmt.Errorf("%w: %s is not in cache %s: %w", ErrOffline, Redact(url), f.CacheDir, ErrNoTile)
 	}
 	if err := os.MkdirAll(filepath.Dir(cp), 0o755); err != nil {
 		return nil, "", err
@@ -81,6 +153,14 @@ func (f *Fetcher) GetTile(ctx context.Context, url string, headers map[string]st
 
 	var lastErr error
 	for attempt := 0; attempt < f.MaxRetries; attempt++ {
+		if attempt > 0 {
+			if err := sleepCtx(ctx, backoff(attempt)); err != nil {
+				return nil, "", err
+			}
+		}
+		if err := f.waitPause(ctx); err != nil {
+			return nil, "", err
+		}
 		if err := f.Limiter.Wait(ctx); err != nil {
 			return nil, "", err
 		}
@@ -92,42 +172,92 @@ func (f *Fetcher) GetTile(ctx context.Context, url string, headers map[string]st
 		for k, v := range headers {
 			req.Header.Set(k, v)
 		}
+		if cerr == nil && !meta.Empty {
+			if meta.ETag != "" {
+				req.Header.Set("If-None-Match", meta.ETag)
+			}
+			if meta.LastModified != "" {
+				req.Header.Set("If-Modified-Since", meta.LastModified)
+			}
+		}
 
 		resp, err := f.Client.Do(req)
 		if err != nil {
-			lastErr = err
-			time.Sleep(time.Duration(200+attempt*200) * time.Millisecond)
+			lastErr = RedactErr(err)
 			continue
 		}
+		var body []byte
+		var ct string
+		class := classify(resp.StatusCode, want)
 		func() {
 			defer resp.Body.Close()
-			if resp.StatusCode != 200 {
-				lastErr = fmt.Errorf("tile HTTP %d for %s", resp.StatusCode, url)
+			switch class {
+			case respNotModified:
+				if cerr != nil || meta.Empty {
+					// unasked-for 304: nothing to reuse, ask again
+					class, lastErr = respRetry, fmt.Errorf("tile HTTP 304 for %s without a cached copy", Redact(url))
+					return
+				}
+				lastErr = writeMeta(cp, metaFromResponse(resp.Header, meta, time.Now()))
+				return
+			case respEmpty:
+				m := metaFromResponse(resp.Header, cacheMeta{}, time.Now())
+				m.Empty = true
+				old := entrySize(cp)
+				_ = os.WriteFile(cp, nil, 0o644)
+				_ = os.Remove(cp + ".ct")
+				_ = writeMeta(cp, m)
+				f.account(entrySize(cp) - old)
+				lastErr = fmt.Errorf("tile HTTP %d for %s: %w", resp.StatusCode, Redact(url), ErrNoTile)
+				return
+			case respThrottled:
+				d := retryAfter(resp.Header.Get("Retry-After"), time.Now())
+				f.pause(d)
+				lastErr = fmt.Errorf("tile HTTP %d for %s (throttled, retry after %s)", resp.StatusCode, Redact(url), d.Round(time.Millisecond))
+				return
+			case respAuth:
+				lastErr = fmt.Errorf("tile HTTP %d for %s: %w: check the API key (-secrets, ${VAR} in the template) and the provider's usage policy", resp.StatusCode, Redact(url), ErrAccessDenied)
+				return
+			case respNoRange:
+				lastErr = fmt.Errorf("%s: server ignores Range requests", Redact(url))
+				return
+			case respFail, respRetry:
+				lastErr = fmt.Errorf("tile HTTP %d for %s", resp.StatusCode, Redact(url))
 				return
 			}
-			body, err := io.ReadAll(resp.Body)
+			body, err = io.ReadAll(resp.Body)
 			if err != nil {
-				lastErr = err
+				class, lastErr = respRetry, err
 				return
 			}
+			old := entrySize(cp) // an overwritten stale copy frees its bytes
 			tmp := cp + ".tmp"
 			if err := os.WriteFile(tmp, body, 0o644); err != nil {
 				lastErr = err
 				return
 			}
-			ct := resp.Header.Get("Content-Type")
+			ct = resp.Header.Get("Content-Type")
 			_ = os.WriteFile(cp+".ct", []byte(ct), 0o644)
+			_ = writeMeta(cp, metaFromResponse(resp.Header, cacheMeta{}, time.Now()))
 			lastErr = os.Rename(tmp, cp)
-		}()
-		if lastErr == nil {
-			data, _ := os.ReadFile(cp)
-			ct := ""
-			if b, err := os.ReadFile(cp + ".ct"); err == nil {
-				ct = string(b)
+			if lastErr == nil {
+				f.account(entrySize(cp) - old)
 			}
-			return data, ct, nil
+		}()
+		switch {
+		case class == respNotModified && lastErr == nil:
+			touch(cp)
+			return cached, cachedCT, nil
+		case class == respOK && lastErr == nil:
+			return body, ct, nil
+		case class == respEmpty, class == respAuth, class == respNoRange, class == respFail:
+			// permanent: retrying gives the same answer and a stale copy would hide it
+			return nil, "", lastErr
 		}
-		time.Sleep(time.Duration(400+attempt*250) * time.Millisecond)
+	}
+	if cerr == nil && !meta.Empty {
+		// a stale tile beats no tile
+		return cached, cachedCT, nil
 	}
 	return nil, "", lastErr
 }
@@ -0,0 +1,55 @@
+package tiles
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetcherOffline(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Path == "/1/0/1.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("tile " + r.URL.Path))
+	}))
+	defer srv.Close()
+
+	f, err := NewFetcher(t.TempDir(), 1000, 10, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, _, err := f.GetTile(ctx, srv.URL+"/1/0/0.png", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := f.GetTile(ctx, srv.URL+"/1/0/1.png", nil); !errors.Is(err, ErrNoTile) {
+		t.Fatalf("404: %v", err)
+	}
+
+	f.Offline = true
+	f.TTL = time.Nanosecond // stale entries are still served offline
+	hits.Store(0)
+	if b, _, err := f.GetTile(ctx, srv.URL+"/1/0/0.png", nil); err != nil || string(b) != "tile /1/0/0.png" {
+		t.Errorf("cached tile: %q, %v", b, err)
+	}
+	_, _, err = f.GetTile(ctx, srv.URL+"/1/0/1.png", nil)
+	if !errors.Is(err, ErrNoTile) || errors.Is(err, ErrOffline) {
+		t.Errorf("cached 404: %v, want ErrNoTile only", err)
+	}
+	_, _, err = f.GetTile(ctx, srv.URL+"/1/1/1.png", nil)
+	if !errors.Is(err, ErrOffline) || !errors.Is(err, ErrNoTile) {
+		t.Errorf("cache miss: %v, want ErrOffline and ErrNoTile", err)
+	}
+	if n := hits.Load(); n != 0 {
+		t.Errorf("offline fetcher made %d requests", n)
+	}
+}
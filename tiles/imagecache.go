@@ -0,0 +1,89 @@
+package tiles
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"image"
+	"sync"
+)
+
+// ImageCache keeps the most recently used decoded tiles of a Source in
+// memory. A moving camera rebuilds the mosaic for every frame; with the
+// cache only the tiles that scrolled into view are fetched and decoded.
+type ImageCache struct {
+	Src Source
+
+	max   int
+	mu    sync.Mutex
+	lru   *list.List // front is the most recently used
+	items map[tileXY]*list.Element
+}
+
+type cachedImage struct {
+	t   tileXY
+	img image.Image
+}
+
+// NewImageCache holds up to max decoded tiles (256×256 RGBA is 256 KiB each).
+func NewImageCache(src Source, max int) *ImageCache {
+	return &ImageCache{Src: src, max: max, lru: list.New(), items: map[tileXY]*list.Element{}}
+}
+
+// Tile passes through to Src; Prefetch only needs the bytes.
+func (c *ImageCache) Tile(ctx context.Context, z, x, y int) ([]byte, error) {
+	return c.Src.Tile(ctx, z, x, y)
+}
+
+func (c *ImageCache) image(ctx context.Context, t tileXY) (image.Image, error) {
+	c.mu.Lock()
+	if el, ok := c.items[t]; ok {
+		c.lru.MoveToFront(el)
+		img := el.Value.(*cachedImage).img
+		c.mu.Unlock()
+		return img, nil
+	}
+	c.mu.Unlock()
+
+	img, err := tileImage(ctx, c.Src, t)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.items[t]; !ok {
+		c.items[t] = c.lru.PushFront(&cachedImage{t, img})
+		for c.lru.Len() > c.max {
+			old := c.lru.Back()
+			c.lru.Remove(old)
+			delete(c.items, old.Value.(*cachedImage).t)
+		}
+	}
+	return img, nil
+}
+
+func (c *ImageCache) workers() int { return workersFor(c.Src) }
+
+// tileImage returns the decoded tile t of src, from memory when src is an
+// ImageCache. The image is shared and must not be modified.
+func tileImage(ctx context.Context, src Source, t tileXY) (image.Image, error) {
+	if c, ok := src.(interface {
+		image(ctx context.Context, t tileXY) (image.Image, error)
+	}); ok {
+		return c.image(ctx, t)
+	}
+	return decodeSourceTile(ctx, src, t)
+}
+
+func decodeSourceTile(ctx context.Context, src Source, t tileXY) (image.Image, error) {
+	data, err := src.Tile(ctx, t.z, t.x, t.y)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := decodeTile(data)
+	if err != nil {
+		return nil, fmt.Errorf("decode tile %d/%d/%d: %w", t.z, t.x, t.y, err)
+	}
+	return fitTile(img), nil
+}
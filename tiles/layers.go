@@ -0,0 +1,149 @@
+package tiles
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// BlendMode is how a layer's colors combine with the layers below it.
+type BlendMode string
+
+const (
+	BlendNormal   BlendMode = "normal"
+	BlendMultiply BlendMode = "multiply" // hillshade, relief: darkens only
+	BlendScreen   BlendMode = "screen"   // lightens only
+	BlendOverlay  BlendMode = "overlay"
+	BlendDarken   BlendMode = "darken"
+	BlendLighten  BlendMode = "lighten"
+)
+
+// ParseBlend accepts a blend mode name; "" means normal.
+func ParseBlend(s string) (BlendMode, error) {
+	switch m := BlendMode(strings.ToLower(strings.TrimSpace(s))); m {
+	case "":
+		return BlendNormal, nil
+	case BlendNormal, BlendMultiply, BlendScreen, BlendOverlay, BlendDarken, BlendLighten:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown blend mode %q (normal | multiply | screen | overlay | darken | lighten)", s)
+}
+
+// Layer is one tile source of a layered map style. The first layer is the
+// base; the others are composited over it in order.
+type Layer struct {
+	Src     Source
+	Preset  Preset    // name, attribution and zoom range
+	Opacity float64   // 0..1
+	Blend   BlendMode // "" is normal
+}
+
+// BuildLayeredMosaic builds the base layer's mosaic at the zoom BuildMosaic
+// picks for it and composites the other layers over it. A layer whose zoom
+// range doesn't include that zoom is fetched at the nearest zoom it has and
+// scaled to the base mosaic.
+func BuildLayeredMosaic(
+	ctx context.Context,
+	layers []Layer,
+	minLon, minLat, maxLon, maxLat float64,
+	targetW, targetH int,
+) (*image.RGBA, int, error) {
+	if len(layers) == 0 {
+		return nil, 0, fmt.Errorf("no map layers")
+	}
+	base := layers[0].Preset
+	z := ClampZoom(FitZoom(minLon, minLat, maxLon, maxLat, targetW, targetH, base), base)
+	var out *image.RGBA
+	for i, l := range layers {
+		lz := ClampZoom(z, l.Preset)
+		s := math.Ldexp(1, lz-z) // layer pixels per base pixel
+		m, err := buildMosaicAt(ctx, l.Src, lz, minLon, minLat, maxLon, maxLat,
+			int(math.Ceil(float64(targetW)*s)), int(math.Ceil(float64(targetH)*s)))
+		if err != nil {
+			return nil, z, fmt.Errorf("layer %d (%s): %w", i+1, l.Preset.Name, err)
+		}
+		if out == nil && l.Opacity >= 1 && (l.Blend == "" || l.Blend == BlendNormal) {
+			out = m // opaque base: nothing below it to blend with
+			continue
+		}
+		if out == nil {
+			out = image.NewRGBA(m.Bounds())
+		}
+		if lz != z {
+			scaled := image.NewRGBA(out.Bounds())
+			xdraw.CatmullRom.Scale(scaled, scaled.Bounds(), m, m.Bounds(), xdraw.Src, nil)
+			m = scaled
+		}
+		Composite(out, m, l.Blend, l.Opacity)
+	}
+	return out, z, nil
+}
+
+// PrefetchLayers warms the cache of every layer at the zoom
+// BuildLayeredMosaic will use for it.
+func PrefetchLayers(ctx context.Context, layers []Layer, boxes [][4]float64, targetW, targetH int) error {
+	base := layers[0].Preset
+	for i, l := range layers {
+		err := prefetch(ctx, l.Src, boxes, func(b [4]float64) int {
+			return ClampZoom(ClampZoom(FitZoom(b[0], b[1], b[2], b[3], targetW, targetH, base), base), l.Preset)
+		})
+		if err != nil {
+			return fmt.Errorf("layer %d (%s): %w", i+1, l.Preset.Name, err)
+		}
+	}
+	return nil
+}
+
+// Composite draws src over dst (same bounds origin) with the given blend mode
+// and opacity, following the W3C separable blend and source-over formulas.
+func Composite(dst, src *image.RGBA, mode BlendMode, opacity float64) {
+	opacity = math.Max(0, math.Min(1, opacity))
+	b := dst.Bounds().Intersect(src.Bounds())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		di := dst.PixOffset(b.Min.X, y)
+		si := src.PixOffset(b.Min.X, y)
+		for x := b.Min.X; x < b.Max.X; x, di, si = x+1, di+4, si+4 {
+			as := float64(src.Pix[si+3]) / 255 * opacity
+			if as == 0 {
+				continue
+			}
+			ab := float64(dst.Pix[di+3]) / 255
+			ao := as + ab*(1-as)
+			for c := 0; c < 3; c++ {
+				// Pix is premultiplied; blend functions work on straight colors
+				cs := float64(src.Pix[si+c]) / float64(src.Pix[si+3])
+				cb := 0.0
+				if ab > 0 {
+					cb = float64(dst.Pix[di+c]) / 255 / ab
+				}
+				mixed := (1-ab)*cs + ab*blend(mode, cb, cs)
+				co := as*mixed + (1-as)*ab*cb // premultiplied result
+				dst.Pix[di+c] = uint8(math.Round(math.Min(co, ao) * 255))
+			}
+			dst.Pix[di+3] = uint8(math.Round(ao * 255))
+		}
+	}
+}
+
+func blend(mode BlendMode, cb, cs float64) float64 {
+	switch mode {
+	case BlendMultiply:
+		return cb * cs
+	case BlendScreen:
+		return cb + cs - cb*cs
+	case BlendOverlay:
+		if cb <= 0.5 {
+			return 2 * cb * cs
+		}
+		return 1 - 2*(1-cb)*(1-cs)
+	case BlendDarken:
+		return math.Min(cb, cs)
+	case BlendLighten:
+		return math.Max(cb, cs)
+	}
+	return cs
+}
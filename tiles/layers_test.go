@@ -0,0 +1,155 @@
+package tiles
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"sync"
+	"testing"
+)
+
+// solidSource serves uniformly colored tiles and records requested zooms.
+type solidSource struct {
+	c     color.Color
+	mu    sync.Mutex
+	zooms map[int]int
+}
+
+func (s *solidSource) Tile(_ context.Context, z, x, y int) ([]byte, error) {
+	s.mu.Lock()
+	if s.zooms == nil {
+		s.zooms = map[int]int{}
+	}
+	s.zooms[z]++
+	s.mu.Unlock()
+	img := image.NewRGBA(image.Rect(0, 0, TileSize, TileSize))
+	draw.Draw(img, img.Bounds(), &image.Uniform{s.c}, image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	return buf.Bytes(), err
+}
+
+func TestBuildLayeredMosaicLayerZoom(t *testing.T) {
+	// a box about 150×120 px at zoom 12
+	minLon, minLat, maxLon, maxLat := 30.0, 59.98, 30.05, 60.0
+	for _, tc := range []struct {
+		name     string
+		min, max int
+		wantZoom int
+	}{
+		{"same range", 0, 18, 12},
+		{"overlay stops below", 0, 10, 10},
+		{"overlay starts above", 13, 16, 13},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			base := &solidSource{c: color.RGBA{200, 100, 50, 255}}
+			over := &solidSource{c: color.RGBA{128, 128, 128, 255}}
+			layers := []Layer{
+				{Src: base, Preset: Preset{MinZoom: 0, MaxZoom: 18}, Opacity: 1},
+				{Src: over, Preset: Preset{MinZoom: tc.min, MaxZoom: tc.max}, Opacity: 1, Blend: BlendMultiply},
+			}
+			out, z, err := BuildLayeredMosaic(context.Background(), layers, minLon, minLat, maxLon, maxLat, 256, 256)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if z != 12 {
+				t.Fatalf("base zoom %d, want 12", z)
+			}
+			if len(over.zooms) != 1 || over.zooms[tc.wantZoom] == 0 {
+				t.Errorf("overlay fetched at zooms %v, want only %d", over.zooms, tc.wantZoom)
+			}
+			b := out.Bounds()
+			if b.Dx() > 256 || b.Dy() > 256 {
+				t.Errorf("mosaic %v exceeds the target", b)
+			}
+			// the overlay covers the whole base box: every pixel is multiplied
+			for _, p := range []image.Point{b.Min, b.Max.Sub(image.Pt(1, 1)), {b.Dx() / 2, b.Dy() / 2}} {
+				if c := out.RGBAAt(p.X, p.Y); c != (color.RGBA{100, 50, 25, 255}) {
+					t.Errorf("pixel %v = %v, want {100 50 25 255}", p, c)
+				}
+			}
+		})
+	}
+}
+
+func TestPrefetchLayersLayerZoom(t *testing.T) {
+	base := &solidSource{c: color.White}
+	over := &solidSource{c: color.Black}
+	layers := []Layer{
+		{Src: base, Preset: Preset{MinZoom: 0, MaxZoom: 18}, Opacity: 1},
+		{Src: over, Preset: Preset{MinZoom: 0, MaxZoom: 10}, Opacity: 1},
+	}
+	boxes := [][4]float64{{30.0, 59.98, 30.05, 60.0}}
+	if err := PrefetchLayers(context.Background(), layers, boxes, 256, 256); err != nil {
+		t.Fatal(err)
+	}
+	if len(base.zooms) != 1 || base.zooms[12] == 0 {
+		t.Errorf("base prefetched at zooms %v, want 12", base.zooms)
+	}
+	if len(over.zooms) != 1 || over.zooms[10] == 0 {
+		t.Errorf("overlay prefetched at zooms %v, want 10", over.zooms)
+	}
+}
+
+func TestComposite(t *testing.T) {
+	gray := func(v uint8) color.RGBA { return color.RGBA{v, v, v, 255} }
+	for _, tc := range []struct {
+		name     string
+		dst, src color.RGBA // Pix values, premultiplied
+		mode     BlendMode
+		opacity  float64
+		want     color.RGBA
+	}{
+		{"normal opaque", gray(10), gray(200), BlendNormal, 1, gray(200)},
+		{"normal half opacity", gray(0), gray(255), BlendNormal, 0.5, gray(128)},
+		{"empty mode is normal", gray(0), gray(255), "", 0.5, gray(128)},
+		{"zero opacity", gray(77), gray(255), BlendNormal, 0, gray(77)},
+		{"opacity clamped", gray(0), gray(255), BlendNormal, 3, gray(255)},
+		{"transparent src", gray(77), color.RGBA{}, BlendMultiply, 1, gray(77)},
+		{"premultiplied src", color.RGBA{200, 100, 50, 255}, color.RGBA{64, 0, 0, 128}, BlendNormal, 1, color.RGBA{164, 50, 25, 255}},
+		{"multiply", gray(128), gray(128), BlendMultiply, 1, gray(64)},
+		{"multiply by white", gray(90), gray(255), BlendMultiply, 1, gray(90)},
+		{"multiply half opacity", gray(200), gray(0), BlendMultiply, 0.5, gray(100)},
+		{"screen", gray(128), gray(128), BlendScreen, 1, gray(192)},
+		{"screen with black", gray(90), gray(0), BlendScreen, 1, gray(90)},
+		{"overlay dark base", gray(64), gray(128), BlendOverlay, 1, gray(64)},
+		{"overlay light base", gray(191), gray(128), BlendOverlay, 1, gray(191)},
+		{"overlay light base, dark src", gray(204), gray(51), BlendOverlay, 1, gray(173)},
+		{"darken", color.RGBA{200, 50, 100, 255}, color.RGBA{100, 100, 100, 255}, BlendDarken, 1, color.RGBA{100, 50, 100, 255}},
+		{"lighten", color.RGBA{200, 50, 100, 255}, color.RGBA{100, 100, 100, 255}, BlendLighten, 1, color.RGBA{200, 100, 100, 255}},
+		// nothing below: the blend mode has no effect, only source-over
+		{"multiply over transparent", color.RGBA{}, color.RGBA{255, 0, 0, 255}, BlendMultiply, 0.5, color.RGBA{128, 0, 0, 128}},
+		{"normal over half-transparent", color.RGBA{0, 0, 128, 128}, color.RGBA{255, 0, 0, 255}, BlendNormal, 0.5, color.RGBA{128, 0, 64, 192}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dst := image.NewRGBA(image.Rect(0, 0, 2, 1))
+			src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+			for x := 0; x < 2; x++ {
+				dst.SetRGBA(x, 0, tc.dst)
+				src.SetRGBA(x, 0, tc.src)
+			}
+			Composite(dst, src, tc.mode, tc.opacity)
+			for x := 0; x < 2; x++ {
+				if got := dst.RGBAAt(x, 0); got != tc.want {
+					t.Errorf("pixel %d = %v, want %v", x, got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCompositeBoundsIntersect(t *testing.T) {
+	dst := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	draw.Draw(src, src.Bounds(), &image.Uniform{color.White}, image.Point{}, draw.Src)
+	Composite(dst, src, BlendNormal, 1)
+	if got := dst.RGBAAt(1, 1); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("inside src: %v", got)
+	}
+	if got := dst.RGBAAt(3, 3); got != (color.RGBA{}) {
+		t.Errorf("outside src: %v", got)
+	}
+}
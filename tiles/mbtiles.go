@@ -0,0 +1,132 @@
+package tiles
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// MBTiles reads raster tiles from an MBTiles package (SQLite, TMS row order).
+type MBTiles struct {
+	db   *sql.DB
+	path string
+	meta map[string]string
+}
+
+// OpenMBTiles opens path read-only and loads its metadata table.
+func OpenMBTiles(path string) (*MBTiles, error) {
+	// sqlite reports a missing file as a cryptic CANTOPEN
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("mbtiles: %w", err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	dsn := (&url.URL{Scheme: "file", Path: abs, RawQuery: "mode=ro"}).String()
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("mbtiles %s: %w", path, err)
+	}
+	m := &MBTiles{db: db, path: path, meta: map[string]string{}}
+
+	rows, err := db.Query(`SELECT name, value FROM metadata`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("mbtiles %s: read metadata: %w", path, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k, v sql.NullString
+		if err := rows.Scan(&k, &v); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("mbtiles %s: read metadata: %w", path, err)
+		}
+		m.meta[strings.ToLower(k.String)] = v.String
+	}
+	if err := rows.Err(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("mbtiles %s: read metadata: %w", path, err)
+	}
+	if f := strings.ToLower(m.meta["format"]); f == "pbf" || f == "mvt" {
+		db.Close()
+		return nil, fmt.Errorf("mbtiles %s: vector tiles (%s) are not supported, need png/jpg/webp raster", path, f)
+	}
+	return m, nil
+}
+
+func (m *MBTiles) Close() error { return m.db.Close() }
+
+// Meta returns a value from the metadata table ("" if absent).
+func (m *MBTiles) Meta(name string) string { return m.meta[name] }
+
+// Preset describes the package: name, attribution and zoom range from metadata.
+// Without minzoom/maxzoom in metadata the range is taken from the tiles table.
+func (m *MBTiles) Preset(ctx context.Context) (Preset, error) {
+	p := Preset{
+		Name:        m.meta["name"],
+		Attribution: plainAttribution(m.meta["attribution"]),
+		MinZoom:     -1,
+		MaxZoom:     -1,
+	}
+	if p.Name == "" {
+		p.Name = filepath.Base(m.path)
+	}
+	if v, err := strconv.Atoi(strings.TrimSpace(m.meta["minzoom"])); err == nil {
+		p.MinZoom = v
+	}
+	if v, err := strconv.Atoi(strings.TrimSpace(m.meta["maxzoom"])); err == nil {
+		p.MaxZoom = v
+	}
+	if p.MinZoom < 0 || p.MaxZoom < 0 {
+		var lo, hi sql.NullInt64
+		err := m.db.QueryRowContext(ctx, `SELECT MIN(zoom_level), MAX(zoom_level) FROM tiles`).Scan(&lo, &hi)
+		if err != nil {
+			return p, fmt.Errorf("mbtiles %s: zoom range: %w", m.path, err)
+		}
+		if !lo.Valid {
+			return p, fmt.Errorf("mbtiles %s: no tiles", m.path)
+		}
+		if p.MinZoom < 0 {
+			p.MinZoom = int(lo.Int64)
+		}
+		if p.MaxZoom < 0 {
+			p.MaxZoom = int(hi.Int64)
+		}
+	}
+	return p, nil
+}
+
+// Tile returns the tile at XYZ address z/x/y; rows are flipped to TMS for the lookup.
+func (m *MBTiles) Tile(ctx context.Context, z, x, y int) ([]byte, error) {
+	var data []byte
+	err := m.db.QueryRowContext(ctx,
+		`SELECT tile_data FROM tiles WHERE zoom_level = ? AND tile_column = ? AND tile_row = ?`,
+		z, x, (1<<z)-1-y,
+	).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("mbtiles %s: %d/%d/%d: %w", filepath.Base(m.path), z, x, y, ErrNoTile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mbtiles %s: %d/%d/%d: %w", filepath.Base(m.path), z, x, y, err)
+	}
+	return data, nil
+}
+
+var tagRe = regexp.MustCompile(`<[^>]*>`)
+
+// attribution in MBTiles metadata is often HTML with links
+func plainAttribution(s string) string {
+	s = html.UnescapeString(tagRe.ReplaceAllString(s, ""))
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,144 @@
+package tiles
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeMBTiles creates an MBTiles file with the given metadata and tiles
+// keyed by their TMS address (tile_row counted from the bottom).
+func writeMBTiles(t *testing.T, meta map[string]string, tms map[tileXY][]byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.mbtiles")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, q := range []string{
+		`CREATE TABLE metadata (name TEXT, value TEXT)`,
+		`CREATE TABLE tiles (zoom_level INTEGER, tile_column INTEGER, tile_row INTEGER, tile_data BLOB)`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for k, v := range meta {
+		if _, err := db.Exec(`INSERT INTO metadata VALUES (?, ?)`, k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for a, data := range tms {
+		if _, err := db.Exec(`INSERT INTO tiles VALUES (?, ?, ?, ?)`, a.z, a.x, a.y, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func openTestMBTiles(t *testing.T, path string) *MBTiles {
+	t.Helper()
+	m, err := OpenMBTiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+func TestMBTilesRowFlip(t *testing.T) {
+	path := writeMBTiles(t, map[string]string{"format": "png"}, map[tileXY][]byte{
+		{2, 1, 3}: []byte("z2 x1 tms3"), // XYZ y = 4-1-3 = 0
+		{2, 1, 0}: []byte("z2 x1 tms0"), // XYZ y = 3
+		{3, 5, 6}: []byte("z3 x5 tms6"), // XYZ y = 8-1-6 = 1
+	})
+	m := openTestMBTiles(t, path)
+	ctx := context.Background()
+	for _, tc := range []struct {
+		z, x, y int
+		want    string
+	}{
+		{2, 1, 0, "z2 x1 tms3"},
+		{2, 1, 3, "z2 x1 tms0"},
+		{3, 5, 1, "z3 x5 tms6"},
+	} {
+		got, err := m.Tile(ctx, tc.z, tc.x, tc.y)
+		if err != nil {
+			t.Errorf("%d/%d/%d: %v", tc.z, tc.x, tc.y, err)
+			continue
+		}
+		if !bytes.Equal(got, []byte(tc.want)) {
+			t.Errorf("%d/%d/%d = %q, want %q", tc.z, tc.x, tc.y, got, tc.want)
+		}
+	}
+	if _, err := m.Tile(ctx, 3, 5, 6); !errors.Is(err, ErrNoTile) {
+		t.Errorf("unflipped address: %v, want ErrNoTile", err)
+	}
+}
+
+func TestMBTilesPreset(t *testing.T) {
+	tiles := map[tileXY][]byte{{3, 0, 0}: {1}, {5, 0, 0}: {1}, {9, 0, 0}: {1}}
+	for _, tc := range []struct {
+		name       string
+		meta       map[string]string
+		wantName   string
+		wantAttr   string
+		minZ, maxZ int
+	}{
+		{
+			name: "metadata",
+			meta: map[string]string{
+				"name":        "Карта",
+				"attribution": `<a href="https://osm.org/copyright">&copy; OpenStreetMap</a>  contributors`,
+				"minzoom":     "4",
+				"maxzoom":     " 12 ",
+			},
+			wantName: "Карта",
+			wantAttr: "© OpenStreetMap contributors",
+			minZ:     4, maxZ: 12,
+		},
+		{
+			name:     "range from tiles",
+			meta:     map[string]string{"format": "png"},
+			wantName: "test.mbtiles",
+			minZ:     3, maxZ: 9,
+		},
+		{
+			name:     "only maxzoom",
+			meta:     map[string]string{"maxzoom": "7"},
+			wantName: "test.mbtiles",
+			minZ:     3, maxZ: 7,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := openTestMBTiles(t, writeMBTiles(t, tc.meta, tiles))
+			p, err := m.Preset(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Name != tc.wantName || p.Attribution != tc.wantAttr || p.MinZoom != tc.minZ || p.MaxZoom != tc.maxZ {
+				t.Errorf("got %q %q z%d-%d, want %q %q z%d-%d",
+					p.Name, p.Attribution, p.MinZoom, p.MaxZoom, tc.wantName, tc.wantAttr, tc.minZ, tc.maxZ)
+			}
+		})
+	}
+}
+
+func TestMBTilesErrors(t *testing.T) {
+	if _, err := OpenMBTiles(filepath.Join(t.TempDir(), "missing.mbtiles")); err == nil {
+		t.Error("missing file: no error")
+	}
+	vec := writeMBTiles(t, map[string]string{"format": "pbf"}, nil)
+	if _, err := OpenMBTiles(vec); err == nil || !strings.Contains(err.Error(), "vector") {
+		t.Errorf("vector package: %v", err)
+	}
+	empty := openTestMBTiles(t, writeMBTiles(t, nil, nil))
+	if _, err := empty.Preset(context.Background()); err == nil {
+		t.Error("empty package: no error")
+	}
+}
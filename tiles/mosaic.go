@@ -8,17 +8,21 @@ import (
 	"image/jpeg"
 	"image/png"
 	"math"
+	"sync"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // MBTiles/PMTiles packages and some servers use webp
 
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
 )
 
-// BuildMosaic fetches all tiles covering bbox and assembles into an RGBA image.
-// It returns the mosaic and the actual zoom used.
+// BuildMosaic fetches all tiles covering bbox from src and assembles into an RGBA image.
+// The preset supplies the zoom range. It returns the mosaic and the actual zoom used.
 func BuildMosaic(
 	ctx context.Context,
-	f *Fetcher,
+	src Source,
 	preset Preset,
 	minLon, minLat, maxLon, maxLat float64,
 	targetW, targetH int,
@@ -27,17 +31,28 @@ func BuildMosaic(
 	// pick zoom to fit into target size
 	z := FitZoom(minLon, minLat, maxLon, maxLat, targetW, targetH, preset)
 	z = ClampZoom(z, preset)
+	out, err := buildMosaicAt(ctx, src, z, minLon, minLat, maxLon, maxLat, targetW, targetH)
+	return out, z, err
+}
 
+// buildMosaicAt assembles the mosaic of bbox at zoom z, at most maxW×maxH.
+func buildMosaicAt(
+	ctx context.Context,
+	src Source,
+	z int,
+	minLon, minLat, maxLon, maxLat float64,
+	maxW, maxH int,
+) (*image.RGBA, error) {
 	// world-pixel bbox at chosen zoom
 	tlx, tly, brx, bry := BBoxPixels(minLon, minLat, maxLon, maxLat, z)
 	w := int(math.Ceil(brx - tlx))
 	h := int(math.Ceil(bry - tly))
 	if w <= 0 || h <= 0 {
-		return nil, z, fmt.Errorf("invalid mosaic size %dx%d", w, h)
+		return nil, fmt.Errorf("invalid mosaic size %dx%d", w, h)
 	}
-	if w > targetW || h > targetH {
+	if w > maxW || h > maxH {
 		// safety (shouldn't happen with FitZoom)
-		w, h = targetW, targetH
+		w, h = maxW, maxH
 	}
 
 	out := image.NewRGBA(image.Rect(0, 0, w, h))
@@ -45,35 +60,52 @@ func BuildMosaic(
 	// range of tiles to fetch
 	minTX, minTY, maxTX, maxTY := CoveringTiles(minLon, minLat, maxLon, maxLat, z)
 
+	var list []tileXY
 	for ty := minTY; ty <= maxTY; ty++ {
 		for tx := minTX; tx <= maxTX; tx++ {
-			u, hdrs, err := f.URLFor(preset, z, tx, ty)
-			if err != nil {
-				return nil, z, err
-			}
-			data, _, err := f.GetTile(ctx, u, hdrs)
-			if err != nil {
-				return nil, z, fmt.Errorf("get tile %s: %w", u, err)
-			}
+			list = append(list, tileXY{z, tx, ty})
+		}
+	}
 
-			img, _, err := decodeTile(data)
-			if err != nil {
-				return nil, z, fmt.Errorf("decode tile %s: %w", u, err)
-			}
+	// tiles are fetched and decoded concurrently (or taken from an ImageCache)
+	// and pasted as they arrive
+	var mu sync.Mutex
+	err := forEachTile(ctx, workersFor(src), list, func(ctx context.Context, t tileXY) error {
+		img, err := tileImage(ctx, src, t)
+		if err != nil {
+			return err
+		}
 
-			// where to paste this tile in mosaic?
-			// compute top-left world-pixel of tile
-			tilePx := float64(tx * TileSize)
-			tilePy := float64(ty * TileSize)
+		// where to paste this tile in mosaic?
+		// compute top-left world-pixel of tile
+		tilePx := float64(t.x * TileSize)
+		tilePy := float64(t.y * TileSize)
 
-			// offset in mosaic:
-			offX := int(tilePx - tlx)
-			offY := int(tilePy - tly)
+		// offset in mosaic (floor: truncating a negative offset overlaps
+		// the neighbour by a pixel and the paste order would show through)
+		offX := int(math.Floor(tilePx - tlx))
+		offY := int(math.Floor(tilePy - tly))
 
-			Paste(out, img, offX, offY)
-		}
+		mu.Lock()
+		Paste(out, img, offX, offY)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return out, z, nil
+	return out, nil
+}
+
+// fitTile scales tiles of another size (e.g. @2x retina) to TileSize.
+func fitTile(img image.Image) image.Image {
+	b := img.Bounds()
+	if b.Dx() == TileSize && b.Dy() == TileSize {
+		return img
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, TileSize, TileSize))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, b, xdraw.Src, nil)
+	return dst
 }
 
 func decodeTile(b []byte) (image.Image, string, error) {
@@ -90,3 +122,44 @@ func decodeTile(b []byte) (image.Image, string, error) {
 	img, format, err := image.Decode(bytes.NewReader(b))
 	return img, format, err
 }
+
+// Prefetch warms the tile cache for every bbox the camera will show.
+// Each box uses the same zoom BuildMosaic would pick for targetW×targetH,
+// so later BuildMosaic calls for these boxes are served from cache.
+func Prefetch(
+	ctx context.Context,
+	src Source,
+	preset Preset,
+	boxes [][4]float64, // minLon, minLat, maxLon, maxLat
+	targetW, targetH int,
+) error {
+	return prefetch(ctx, src, boxes, func(b [4]float64) int {
+		return ClampZoom(FitZoom(b[0], b[1], b[2], b[3], targetW, targetH, preset), preset)
+	})
+}
+
+// prefetch fetches the tiles covering each box at the zoom picked for it.
+func prefetch(ctx context.Context, src Source, boxes [][4]float64, zoom func(b [4]float64) int) error {
+	seen := map[tileXY]bool{}
+	var list []tileXY
+	for _, b := range boxes {
+		z := zoom(b)
+		minTX, minTY, maxTX, maxTY := CoveringTiles(b[0], b[1], b[2], b[3], z)
+		for ty := minTY; ty <= maxTY; ty++ {
+			for tx := minTX; tx <= maxTX; tx++ {
+				k := tileXY{z, tx, ty}
+				if seen[k] {
+					continue
+				}
+				seen[k] = true
+				list = append(list, k)
+			}
+		}
+	}
+	return forEachTile(ctx, workersFor(src), list, func(ctx context.Context, t tileXY) error {
+		if _, err := src.Tile(ctx, t.z, t.x, t.y); err != nil {
+			return fmt.Errorf("prefetch: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,372 @@
+package tiles
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// PMTiles reads raster tiles from a PMTiles v3 archive, either a local file
+// or a remote one through HTTP range requests.
+// Spec: https://github.com/protomaps/PMTiles/blob/main/spec/v3/spec.md
+type PMTiles struct {
+	r      rangeReader
+	name   string
+	closer io.Closer
+	hdr    pmHeader
+	root   []pmEntry
+
+	mu     sync.Mutex
+	leaves map[uint64][]pmEntry // by offset in the leaf directories section
+}
+
+const pmHeaderLen = 127
+
+// compression and tile type codes from the spec
+const (
+	pmCompUnknown = 0
+	pmCompNone    = 1
+	pmCompGzip    = 2
+	pmCompBrotli  = 3
+	pmCompZstd    = 4
+
+	pmTypeMVT = 1
+)
+
+type pmHeader struct {
+	rootOff, rootLen uint64
+	metaOff, metaLen uint64
+	leafOff, leafLen uint64
+	dataOff, dataLen uint64
+
+	internalComp byte
+	tileComp     byte
+	tileType     byte
+	minZoom      int
+	maxZoom      int
+}
+
+type pmEntry struct {
+	TileID    uint64
+	Offset    uint64
+	Length    uint64
+	RunLength uint64 // 0 marks a leaf directory
+}
+
+// rangeReader reads n bytes at offset off of an archive.
+type rangeReader interface {
+	readRange(ctx context.Context, off, n uint64) ([]byte, error)
+}
+
+type fileRange struct{ f *os.File }
+
+func (r fileRange) readRange(_ context.Context, off, n uint64) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := r.f.ReadAt(b, int64(off)); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+type httpRange struct {
+	f       *Fetcher
+	url     string
+	headers map[string]string
+}
+
+func (r httpRange) readRange(ctx context.Context, off, n uint64) ([]byte, error) {
+	return r.f.GetRange(ctx, r.url, r.headers, off, n)
+}
+
+// OpenPMTiles opens a local archive and reads its header and root directory.
+func OpenPMTiles(ctx context.Context, path string) (*PMTiles, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("pmtiles: %w", err)
+	}
+	p, err := openPMTiles(ctx, fileRange{f}, filepath.Base(path))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	p.closer = f
+	return p, nil
+}
+
+// OpenPMTilesURL opens a remote archive. Byte ranges go through f, so they
+// share its rate limit, retries and disk cache.
+func OpenPMTilesURL(ctx context.Context, f *Fetcher, url string, headers map[string]string) (*PMTiles, error) {
+	name := Redact(url)
+	if i := strings.IndexByte(name, '?'); i >= 0 {
+		name = name[:i]
+	}
+	return openPMTiles(ctx, httpRange{f: f, url: url, headers: headers}, filepath.Base(name))
+}
+
+func openPMTiles(ctx context.Context, r rangeReader, name string) (*PMTiles, error) {
+	p := &PMTiles{r: r, name: name, leaves: map[uint64][]pmEntry{}}
+	b, err := r.readRange(ctx, 0, pmHeaderLen)
+	if err != nil {
+		return nil, p.errf("read header: %w", err)
+	}
+	if p.hdr, err = parsePMHeader(b); err != nil {
+		return nil, p.errf("%w", err)
+	}
+	switch p.hdr.internalComp {
+	case pmCompNone, pmCompGzip, pmCompUnknown:
+	default:
+		return nil, p.errf("directory compression %s is not supported", compName(p.hdr.internalComp))
+	}
+	switch p.hdr.tileComp {
+	case pmCompNone, pmCompGzip, pmCompUnknown:
+	default:
+		return nil, p.errf("tile compression %s is not supported", compName(p.hdr.tileComp))
+	}
+	if p.hdr.tileType == pmTypeMVT {
+		return nil, p.errf("vector tiles (mvt) are not supported, need png/jpg/webp raster")
+	}
+	if p.root, err = p.readDir(ctx, p.hdr.rootOff, p.hdr.rootLen); err != nil {
+		return nil, p.errf("root directory: %w", err)
+	}
+	return p, nil
+}
+
+func parsePMHeader(b []byte) (pmHeader, error) {
+	var h pmHeader
+	if len(b) < pmHeaderLen || string(b[:7]) != "PMTiles" {
+		return h, errors.New("not a PMTiles archive")
+	}
+	if b[7] != 3 {
+		return h, fmt.Errorf("PMTiles version %d is not supported, need 3", b[7])
+	}
+	u := func(at int) uint64 { return binary.LittleEndian.Uint64(b[at:]) }
+	h.rootOff, h.rootLen = u(8), u(16)
+	h.metaOff, h.metaLen = u(24), u(32)
+	h.leafOff, h.leafLen = u(40), u(48)
+	h.dataOff, h.dataLen = u(56), u(64)
+	h.internalComp = b[97]
+	h.tileComp = b[98]
+	h.tileType = b[99]
+	h.minZoom = int(b[100])
+	h.maxZoom = int(b[101])
+	return h, nil
+}
+
+func compName(c byte) string {
+	switch c {
+	case pmCompBrotli:
+		return "brotli"
+	case pmCompZstd:
+		return "zstd"
+	}
+	return fmt.Sprintf("#%d", c)
+}
+
+func (p *PMTiles) errf(format string, args ...any) error {
+	return fmt.Errorf("pmtiles %s: "+format, append([]any{p.name}, args...)...)
+}
+
+func (p *PMTiles) Close() error {
+	if p.closer != nil {
+		return p.closer.Close()
+	}
+	return nil
+}
+
+// Preset describes the archive: zoom range from the header, name and
+// attribution from the JSON metadata.
+func (p *PMTiles) Preset(ctx context.Context) (Preset, error) {
+	pr := Preset{Name: p.name, MinZoom: p.hdr.minZoom, MaxZoom: p.hdr.maxZoom}
+	if p.hdr.metaLen == 0 {
+		return pr, nil
+	}
+	b, err := p.r.readRange(ctx, p.hdr.metaOff, p.hdr.metaLen)
+	if err != nil {
+		return pr, p.errf("read metadata: %w", err)
+	}
+	if b, err = decompress(b, p.hdr.internalComp); err != nil {
+		return pr, p.errf("metadata: %w", err)
+	}
+	var meta struct {
+		Name        string `json:"name"`
+		Attribution string `json:"attribution"`
+	}
+	if err := json.Unmarshal(b, &meta); err != nil {
+		return pr, p.errf("metadata: %w", err)
+	}
+	if meta.Name != "" {
+		pr.Name = meta.Name
+	}
+	pr.Attribution = plainAttribution(meta.Attribution)
+	return pr, nil
+}
+
+// Tile returns the tile at z/x/y, following leaf directories as needed.
+func (p *PMTiles) Tile(ctx context.Context, z, x, y int) ([]byte, error) {
+	if z < p.hdr.minZoom || z > p.hdr.maxZoom || x < 0 || y < 0 || x >= 1<<z || y >= 1<<z {
+		return nil, p.errf("%d/%d/%d: %w", z, x, y, ErrNoTile)
+	}
+	id := ZxyToID(z, x, y)
+	dir := p.root
+	// the spec bounds the directory depth; 4 levels cover any real archive
+	for depth := 0; depth < 4; depth++ {
+		e, ok := findEntry(dir, id)
+		if !ok {
+			return nil, p.errf("%d/%d/%d: %w", z, x, y, ErrNoTile)
+		}
+		if e.RunLength > 0 {
+			b, err := p.r.readRange(ctx, p.hdr.dataOff+e.Offset, e.Length)
+			if err != nil {
+				return nil, p.errf("%d/%d/%d: %w", z, x, y, err)
+			}
+			if b, err = decompress(b, p.hdr.tileComp); err != nil {
+				return nil, p.errf("%d/%d/%d: %w", z, x, y, err)
+			}
+			return b, nil
+		}
+		var err error
+		if dir, err = p.leaf(ctx, e.Offset, e.Length); err != nil {
+			return nil, p.errf("leaf directory: %w", err)
+		}
+	}
+	return nil, p.errf("%d/%d/%d: leaf directories nested too deep", z, x, y)
+}
+
+// leaf reads a leaf directory once; Tile is called from the mosaic worker pool.
+func (p *PMTiles) leaf(ctx context.Context, off, n uint64) ([]pmEntry, error) {
+	p.mu.Lock()
+	d, ok := p.leaves[off]
+	p.mu.Unlock()
+	if ok {
+		return d, nil
+	}
+	d, err := p.readDir(ctx, p.hdr.leafOff+off, n)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.leaves[off] = d
+	p.mu.Unlock()
+	return d, nil
+}
+
+func (p *PMTiles) readDir(ctx context.Context, off, n uint64) ([]pmEntry, error) {
+	b, err := p.r.readRange(ctx, off, n)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = decompress(b, p.hdr.internalComp); err != nil {
+		return nil, err
+	}
+	return parseDir(b)
+}
+
+// parseDir decodes a directory: entry count, then columns of delta tile IDs,
+// run lengths, lengths and offsets, all as uvarints.
+func parseDir(b []byte) ([]pmEntry, error) {
+	r := bytes.NewReader(b)
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("directory: %w", err)
+	}
+	if n > uint64(len(b)) {
+		return nil, fmt.Errorf("directory: bad entry count %d", n)
+	}
+	es := make([]pmEntry, n)
+	read := func(set func(i int, v uint64)) error {
+		for i := range es {
+			v, err := binary.ReadUvarint(r)
+			if err != nil {
+				return fmt.Errorf("directory: %w", err)
+			}
+			set(i, v)
+		}
+		return nil
+	}
+	var last uint64
+	if err := read(func(i int, v uint64) { last += v; es[i].TileID = last }); err != nil {
+		return nil, err
+	}
+	if err := read(func(i int, v uint64) { es[i].RunLength = v }); err != nil {
+		return nil, err
+	}
+	if err := read(func(i int, v uint64) { es[i].Length = v }); err != nil {
+		return nil, err
+	}
+	// offset 0 means "right after the previous entry", others are stored +1
+	err = read(func(i int, v uint64) {
+		if v == 0 && i > 0 {
+			es[i].Offset = es[i-1].Offset + es[i-1].Length
+		} else {
+			es[i].Offset = v - 1
+		}
+	})
+	return es, err
+}
+
+// findEntry returns the entry covering id: a tile run containing it or the
+// leaf directory whose range starts at or before it.
+func findEntry(es []pmEntry, id uint64) (pmEntry, bool) {
+	i := sort.Search(len(es), func(i int) bool { return es[i].TileID > id }) - 1
+	if i < 0 {
+		return pmEntry{}, false
+	}
+	e := es[i]
+	if e.RunLength == 0 || id < e.TileID+e.RunLength {
+		return e, true
+	}
+	return pmEntry{}, false
+}
+
+func decompress(b []byte, comp byte) ([]byte, error) {
+	if comp != pmCompGzip {
+		return b, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// ZxyToID maps a tile to its PMTiles ID: the number of tiles on all lower
+// zooms plus the position along the Hilbert curve at zoom z.
+func ZxyToID(z, x, y int) uint64 {
+	acc := (uint64(1)<<(2*z) - 1) / 3
+	for s := 1 << z >> 1; s > 0; s >>= 1 {
+		rx, ry := 0, 0
+		if x&s != 0 {
+			rx = 1
+		}
+		if y&s != 0 {
+			ry = 1
+		}
+		acc += uint64(s) * uint64(s) * uint64((3*rx)^ry)
+		x, y = x&(s-1), y&(s-1)
+		if ry == 0 {
+			if rx == 1 {
+				x, y = s-1-x, s-1-y
+			}
+			x, y = y, x
+		}
+	}
+	return acc
+}
+
+func (p *PMTiles) workers() int {
+	if h, ok := p.r.(httpRange); ok {
+		return h.f.Workers
+	}
+	return DefaultWorkers
+}
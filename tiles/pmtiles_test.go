@@ -0,0 +1,114 @@
+package tiles
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestZxyToID(t *testing.T) {
+	// values from the PMTiles v3 spec reference implementation
+	for _, tc := range []struct {
+		z, x, y int
+		want    uint64
+	}{
+		{0, 0, 0, 0},
+		{1, 0, 0, 1},
+		{1, 0, 1, 2},
+		{1, 1, 1, 3},
+		{1, 1, 0, 4},
+		{2, 0, 0, 5},
+		{3, 7, 0, 84},
+		{12, 3423, 1763, 19078479},
+	} {
+		if got := ZxyToID(tc.z, tc.x, tc.y); got != tc.want {
+			t.Errorf("ZxyToID(%d, %d, %d) = %d, want %d", tc.z, tc.x, tc.y, got, tc.want)
+		}
+	}
+}
+
+// encodeDir serializes entries the way a PMTiles writer does: delta-coded
+// tile IDs, then run lengths, lengths and offsets (0 = contiguous, else +1).
+func encodeDir(es []pmEntry) []byte {
+	b := binary.AppendUvarint(nil, uint64(len(es)))
+	var last uint64
+	for _, e := range es {
+		b = binary.AppendUvarint(b, e.TileID-last)
+		last = e.TileID
+	}
+	for _, e := range es {
+		b = binary.AppendUvarint(b, e.RunLength)
+	}
+	for _, e := range es {
+		b = binary.AppendUvarint(b, e.Length)
+	}
+	for i, e := range es {
+		if i > 0 && e.Offset == es[i-1].Offset+es[i-1].Length {
+			b = binary.AppendUvarint(b, 0)
+		} else {
+			b = binary.AppendUvarint(b, e.Offset+1)
+		}
+	}
+	return b
+}
+
+func TestParseDir(t *testing.T) {
+	want := []pmEntry{
+		{TileID: 0, Offset: 0, Length: 100, RunLength: 1},
+		{TileID: 1, Offset: 100, Length: 50, RunLength: 4},   // contiguous run of 4 identical tiles
+		{TileID: 5, Offset: 900, Length: 70, RunLength: 1},   // explicit offset
+		{TileID: 21, Offset: 970, Length: 300, RunLength: 0}, // leaf directory from 21
+	}
+	got, err := parseDir(encodeDir(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseDirErrors(t *testing.T) {
+	for name, b := range map[string][]byte{
+		"empty":       nil,
+		"bad count":   binary.AppendUvarint(nil, 1000),
+		"truncated":   encodeDir([]pmEntry{{TileID: 1, Length: 10, RunLength: 1}, {TileID: 2, Length: 10, RunLength: 1}})[:4],
+		"short count": {0x80},
+	} {
+		if _, err := parseDir(b); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestFindEntry(t *testing.T) {
+	es := []pmEntry{
+		{TileID: 3, Offset: 0, Length: 10, RunLength: 1},
+		{TileID: 5, Offset: 10, Length: 10, RunLength: 3},  // 5, 6, 7
+		{TileID: 20, Offset: 20, Length: 99, RunLength: 0}, // leaf: 20 and up
+	}
+	for _, tc := range []struct {
+		id     uint64
+		want   uint64 // TileID of the found entry
+		wantOK bool
+	}{
+		{0, 0, false}, // before the first entry
+		{3, 3, true},
+		{4, 0, false}, // gap after a single tile
+		{5, 5, true},
+		{7, 5, true}, // last tile of the run
+		{8, 0, false},
+		{19, 0, false},
+		{20, 20, true},
+		{1000, 20, true}, // leaf covers everything after it
+	} {
+		e, ok := findEntry(es, tc.id)
+		if ok != tc.wantOK || (ok && e.TileID != tc.want) {
+			t.Errorf("findEntry(%d) = %+v, %v; want TileID %d, %v", tc.id, e, ok, tc.want, tc.wantOK)
+		}
+	}
+}
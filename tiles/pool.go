@@ -0,0 +1,65 @@
+package tiles
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultWorkers is the number of concurrent tile requests when Fetcher.Workers is unset.
+const DefaultWorkers = 4
+
+type tileXY struct{ z, x, y int }
+
+// forEachTile runs fn for every tile on a bounded pool of n goroutines.
+// For HTTP sources the request rate is still governed by Fetcher.Limiter
+// inside GetTile. The first error cancels the remaining work and is returned;
+// errors caused by that cancellation are not reported.
+func forEachTile(ctx context.Context, n int, list []tileXY, fn func(ctx context.Context, t tileXY) error) error {
+	if n <= 0 {
+		n = DefaultWorkers
+	}
+	n = min(n, len(list))
+	if n == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		once     sync.Once
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	jobs := make(chan tileXY)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range jobs {
+				if ctx.Err() != nil {
+					continue // canceled: drain without starting the tile
+				}
+				if err := fn(ctx, t); err != nil {
+					once.Do(func() { firstErr = err; cancel() })
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, t := range list {
+		select {
+		case jobs <- t:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,98 @@
+package tiles
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func tileList(n int) []tileXY {
+	list := make([]tileXY, n)
+	for i := range list {
+		list[i] = tileXY{10, i % 32, i / 32}
+	}
+	return list
+}
+
+func TestForEachTileComplete(t *testing.T) {
+	for _, workers := range []int{0, 1, 3, 16, 500} {
+		list := tileList(200)
+		var (
+			mu        sync.Mutex
+			seen      = map[tileXY]int{}
+			active    atomic.Int32
+			maxActive atomic.Int32
+		)
+		err := forEachTile(context.Background(), workers, list, func(_ context.Context, t tileXY) error {
+			n := active.Add(1)
+			defer active.Add(-1)
+			for {
+				m := maxActive.Load()
+				if n <= m || maxActive.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			mu.Lock()
+			seen[t]++
+			mu.Unlock()
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("workers=%d: %v", workers, err)
+		}
+		if len(seen) != len(list) {
+			t.Errorf("workers=%d: %d of %d tiles done", workers, len(seen), len(list))
+		}
+		for tt, c := range seen {
+			if c != 1 {
+				t.Errorf("workers=%d: %v done %d times", workers, tt, c)
+			}
+		}
+		limit := workers
+		if limit <= 0 {
+			limit = DefaultWorkers
+		}
+		if m := int(maxActive.Load()); m > limit {
+			t.Errorf("workers=%d: %d tiles in flight", workers, m)
+		}
+	}
+	if err := forEachTile(context.Background(), 4, nil, nil); err != nil {
+		t.Errorf("empty list: %v", err)
+	}
+}
+
+func TestForEachTileFirstError(t *testing.T) {
+	const workers = 4
+	boom := errors.New("boom")
+	list := tileList(1000)
+	var started atomic.Int32
+	err := forEachTile(context.Background(), workers, list, func(ctx context.Context, t tileXY) error {
+		started.Add(1)
+		if t == list[0] {
+			return boom
+		}
+		// the others wait for the cancel and fail with it
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err != boom {
+		t.Errorf("error %v, want the first one", err)
+	}
+	// at most one tile per worker may have been running when boom canceled the rest
+	if n := started.Load(); n > workers {
+		t.Errorf("%d tiles started after the first error, want <= %d", n, workers)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	started.Store(0)
+	err = forEachTile(ctx, workers, list, func(context.Context, tileXY) error {
+		started.Add(1)
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || started.Load() != 0 {
+		t.Errorf("canceled context: %v, %d tiles started", err, started.Load())
+	}
+}
@@ -3,16 +3,20 @@ package tiles
 import (
 	"fmt"
 	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
 type Preset struct {
 	Name        string
-	URLTmpl     string // .../{z}/{x}/{y}.png
+	URLTmpl     string // .../{z}/{x}/{y}.png, see FillURL for all placeholders
 	Attribution string
 	MinZoom     int
 	MaxZoom     int
 	Headers     map[string]string // optional
+	Subdomains  []string          // for {s}; empty means a, b, c
+	Retina      bool              // {r} becomes "@2x"
 }
 
 var Presets = map[string]Preset{
@@ -42,10 +46,89 @@ var Presets = map[string]Preset{
 	},
 }
 
+// FillURL expands the tile placeholders:
+//
+//	{z} {x} {y}  zoom and XYZ tile column/row
+//	{-y}         TMS row, counted from the bottom
+//	{q}          Bing-style quadkey
+//	{s}          subdomain, picked per tile so a tile always maps to the same host
+//	{r}          "@2x" for retina tiles, empty otherwise
 func (p Preset) FillURL(z, x, y int) (string, error) {
-	u := strings.ReplaceAll(p.URLTmpl, "{z}", fmt.Sprintf("%d", z))
-	u = strings.ReplaceAll(u, "{x}", fmt.Sprintf("%d", x))
-	u = strings.ReplaceAll(u, "{y}", fmt.Sprintf("%d", y))
+	sub := p.Subdomains
+	if len(sub) == 0 {
+		sub = defaultSubdomains
+	}
+	r := ""
+	if p.Retina {
+		r = "@2x"
+	}
+	u := strings.NewReplacer(
+		"{z}", strconv.Itoa(z),
+		"{x}", strconv.Itoa(x),
+		"{y}", strconv.Itoa(y),
+		"{-y}", strconv.Itoa((1<<z)-1-y),
+		"{q}", Quadkey(z, x, y),
+		"{s}", sub[(x+y)%len(sub)],
+		"{r}", r,
+	).Replace(p.URLTmpl)
 	_, err := url.Parse(u)
 	return u, err
 }
+
+var defaultSubdomains = []string{"a", "b", "c"}
+
+var placeholderRe = regexp.MustCompile(`\{[^{}]*\}`)
+
+// Validate checks the URL template before any request: every placeholder
+// must be known and the template must address a tile either by {z} with
+// {x} and {y}/{-y}, or by {q}.
+func (p Preset) Validate() error {
+	t := p.URLTmpl
+	if strings.TrimSpace(t) == "" {
+		return fmt.Errorf("preset %s: empty URL template", p.Name)
+	}
+	// ${VAR} must have been expanded by Expand already
+	if m := varRef.FindString(t); m != "" {
+		return fmt.Errorf("preset %s: unexpanded %s in URL template", p.Name, m)
+	}
+	for _, ph := range placeholderRe.FindAllString(t, -1) {
+		switch ph {
+		case "{z}", "{x}", "{y}", "{-y}", "{q}", "{s}", "{r}":
+		default:
+			return fmt.Errorf("preset %s: unknown placeholder %s (supported: {z} {x} {y} {-y} {q} {s} {r})", p.Name, ph)
+		}
+	}
+	has := func(ph string) bool { return strings.Contains(t, ph) }
+	if !has("{q}") && !(has("{z}") && has("{x}") && (has("{y}") || has("{-y}"))) {
+		return fmt.Errorf("preset %s: URL template needs {z}, {x} and {y} (or {-y}), or {q}", p.Name)
+	}
+	if has("{y}") && has("{-y}") {
+		return fmt.Errorf("preset %s: URL template has both {y} and {-y}", p.Name)
+	}
+	for _, s := range p.Subdomains {
+		if s == "" || strings.ContainsAny(s, "/?#") {
+			return fmt.Errorf("preset %s: bad subdomain %q", p.Name, s)
+		}
+	}
+	if _, err := url.Parse(strings.NewReplacer("{", "", "}", "").Replace(t)); err != nil {
+		return fmt.Errorf("preset %s: %w", p.Name, err)
+	}
+	return nil
+}
+
+// Quadkey returns the Bing Maps quadkey of tile x, y at zoom z.
+func Quadkey(z, x, y int) string {
+	b := make([]byte, z)
+	for i := z; i > 0; i-- {
+		d := byte('0')
+		m := 1 << (i - 1)
+		if x&m != 0 {
+			d++
+		}
+		if y&m != 0 {
+			d += 2
+		}
+		b[z-i] = d
+	}
+	return string(b)
+}
@@ -0,0 +1,97 @@
+package tiles
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQuadkey(t *testing.T) {
+	for _, tc := range []struct {
+		z, x, y int
+		want    string
+	}{
+		{0, 0, 0, ""},
+		{1, 0, 0, "0"},
+		{1, 1, 0, "1"},
+		{1, 0, 1, "2"},
+		{1, 1, 1, "3"},
+		{3, 3, 5, "213"}, // Bing Maps Tile System example
+		{5, 31, 31, "33333"},
+	} {
+		if got := Quadkey(tc.z, tc.x, tc.y); got != tc.want {
+			t.Errorf("Quadkey(%d, %d, %d) = %q, want %q", tc.z, tc.x, tc.y, got, tc.want)
+		}
+	}
+}
+
+func TestFillURL(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		p       Preset
+		z, x, y int
+		want    string
+	}{
+		{"xyz", Preset{URLTmpl: "https://t/{z}/{x}/{y}.png"}, 3, 2, 1, "https://t/3/2/1.png"},
+		{"tms", Preset{URLTmpl: "https://t/{z}/{x}/{-y}.png"}, 3, 2, 1, "https://t/3/2/6.png"},
+		{"tms top row", Preset{URLTmpl: "https://t/{z}/{x}/{-y}.png"}, 0, 0, 0, "https://t/0/0/0.png"},
+		{"quadkey", Preset{URLTmpl: "https://t/{q}.jpg"}, 3, 3, 5, "https://t/213.jpg"},
+		{"default subdomains", Preset{URLTmpl: "https://{s}.t/{z}/{x}/{y}"}, 4, 1, 1, "https://c.t/4/1/1"},
+		{"default subdomains wrap", Preset{URLTmpl: "https://{s}.t/{z}/{x}/{y}"}, 4, 2, 1, "https://a.t/4/2/1"},
+		{"own subdomains", Preset{URLTmpl: "https://{s}.t/{z}/{x}/{y}", Subdomains: []string{"m1", "m2"}}, 4, 2, 1, "https://m2.t/4/2/1"},
+		{"retina", Preset{URLTmpl: "https://t/{z}/{x}/{y}{r}.png", Retina: true}, 1, 0, 1, "https://t/1/0/1@2x.png"},
+		{"no retina", Preset{URLTmpl: "https://t/{z}/{x}/{y}{r}.png"}, 1, 0, 1, "https://t/1/0/1.png"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.p.FillURL(tc.z, tc.x, tc.y)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("FillURL(%d, %d, %d) = %q, want %q", tc.z, tc.x, tc.y, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFillURLSubdomainStable(t *testing.T) {
+	p := Preset{URLTmpl: "https://{s}.t/{z}/{x}/{y}"}
+	a, _ := p.FillURL(7, 10, 20)
+	b, _ := p.FillURL(7, 10, 20)
+	if a != b {
+		t.Errorf("same tile mapped to %q and %q", a, b)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		p       Preset
+		wantErr string // "" means valid
+	}{
+		{"xyz", Preset{URLTmpl: "https://t/{z}/{x}/{y}.png"}, ""},
+		{"tms", Preset{URLTmpl: "https://t/{z}/{x}/{-y}.png"}, ""},
+		{"quadkey", Preset{URLTmpl: "https://t/{q}.jpg"}, ""},
+		{"subdomain and retina", Preset{URLTmpl: "https://{s}.t/{z}/{x}/{y}{r}.png"}, ""},
+		{"own subdomains", Preset{URLTmpl: "https://{s}.t/{z}/{x}/{y}", Subdomains: []string{"a1", "a2"}}, ""},
+		{"empty", Preset{URLTmpl: "  "}, "empty URL template"},
+		{"unexpanded var", Preset{URLTmpl: "https://t/{z}/{x}/{y}?key=${KEY}"}, "unexpanded ${KEY}"},
+		{"unknown placeholder", Preset{URLTmpl: "https://t/{z}/{x}/{y}{ext}"}, "unknown placeholder {ext}"},
+		{"no y", Preset{URLTmpl: "https://t/{z}/{x}.png"}, "needs {z}, {x} and {y}"},
+		{"no z", Preset{URLTmpl: "https://{s}.t/{x}/{y}.png"}, "needs {z}, {x} and {y}"},
+		{"y and -y", Preset{URLTmpl: "https://t/{z}/{x}/{y}/{-y}"}, "both {y} and {-y}"},
+		{"empty subdomain", Preset{URLTmpl: "https://{s}.t/{z}/{x}/{y}", Subdomains: []string{"a", ""}}, "bad subdomain"},
+		{"subdomain with slash", Preset{URLTmpl: "https://{s}.t/{z}/{x}/{y}", Subdomains: []string{"a/b"}}, "bad subdomain"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.p.Validate()
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tc.wantErr != "" && err == nil:
+				t.Errorf("no error, want %q", tc.wantErr)
+			case tc.wantErr != "" && !strings.Contains(err.Error(), tc.wantErr):
+				t.Errorf("error %q, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
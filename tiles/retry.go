@@ -0,0 +1,112 @@
+package tiles
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type respClass int
+
+const (
+	respOK          respClass = iota
+	respNotModified           // 304 to a conditional request
+	respEmpty                 // 404/204/410: no tile at this address, for good
+	respThrottled             // 429/503: wait for Retry-After, then retry
+	respAuth                  // 401/403/407: bad or missing key, fail fast
+	respNoRange               // 200 to a Range request
+	respFail                  // other 4xx: retrying won't help
+	respRetry                 // 5xx and the like
+)
+
+func classify(code, want int) respClass {
+	switch {
+	case code == want:
+		return respOK
+	case code == http.StatusNotModified:
+		return respNotModified
+	case code == http.StatusOK && want == http.StatusPartialContent:
+		return respNoRange
+	case code == http.StatusNotFound || code == http.StatusNoContent || code == http.StatusGone:
+		return respEmpty
+	case code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable:
+		return respThrottled
+	case code == http.StatusUnauthorized || code == http.StatusForbidden || code == http.StatusProxyAuthRequired:
+		return respAuth
+	case code >= 400 && code < 500:
+		return respFail
+	}
+	return respRetry
+}
+
+const (
+	backoffBase = 500 * time.Millisecond
+	backoffMax  = 30 * time.Second
+	// longest Retry-After we are willing to sit out; beyond it the retry
+	// budget runs out quickly and the render fails instead of hanging
+	retryAfterMax = 2 * time.Minute
+)
+
+// backoff is the pause before retry attempt n (1-based): exponential with
+// full jitter, so concurrent workers don't retry in lockstep.
+func backoff(n int) time.Duration {
+	d := backoffBase << (n - 1)
+	if d <= 0 || d > backoffMax {
+		d = backoffMax
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryAfter parses a Retry-After header (seconds or an HTTP date). Without
+// one it falls back to the first backoff step.
+func retryAfter(h string, now time.Time) time.Duration {
+	h = strings.TrimSpace(h)
+	d := time.Duration(-1)
+	if s, err := strconv.Atoi(h); err == nil {
+		d = time.Duration(s) * time.Second
+	} else if t, err := http.ParseTime(h); err == nil {
+		d = t.Sub(now)
+	}
+	switch {
+	case d < 0:
+		return backoff(1)
+	case d > retryAfterMax:
+		return retryAfterMax
+	}
+	return d
+}
+
+// pause holds back every request of this Fetcher for d: a 429 is about the
+// client as a whole, not just the tile that got it.
+func (f *Fetcher) pause(d time.Duration) {
+	until := time.Now().Add(d)
+	f.pauseMu.Lock()
+	if until.After(f.pauseUntil) {
+		f.pauseUntil = until
+	}
+	f.pauseMu.Unlock()
+}
+
+func (f *Fetcher) waitPause(ctx context.Context) error {
+	f.pauseMu.Lock()
+	d := time.Until(f.pauseUntil)
+	f.pauseMu.Unlock()
+	if d <= 0 {
+		return nil
+	}
+	return sleepCtx(ctx, d)
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,76 @@
+package tiles
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestClassify(t *testing.T) {
+	for _, tc := range []struct {
+		code, want int
+		class      respClass
+	}{
+		{200, 200, respOK},
+		{206, 206, respOK},
+		{200, 206, respNoRange},
+		{304, 200, respNotModified},
+		{404, 200, respEmpty},
+		{204, 200, respEmpty},
+		{410, 200, respEmpty},
+		{429, 200, respThrottled},
+		{503, 200, respThrottled},
+		{401, 200, respAuth},
+		{403, 200, respAuth},
+		{407, 200, respAuth},
+		{400, 200, respFail},
+		{416, 206, respFail},
+		{500, 200, respRetry},
+		{502, 200, respRetry},
+		{504, 200, respRetry},
+		{302, 200, respRetry},
+	} {
+		if got := classify(tc.code, tc.want); got != tc.class {
+			t.Errorf("classify(%d, %d) = %d, want %d", tc.code, tc.want, got, tc.class)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name string
+		h    string
+		want time.Duration
+	}{
+		{"seconds", "7", 7 * time.Second},
+		{"seconds with spaces", " 30 ", 30 * time.Second},
+		{"zero", "0", 0},
+		{"http date", now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{"seconds over the cap", "3600", retryAfterMax},
+		{"date over the cap", now.Add(time.Hour).Format(http.TimeFormat), retryAfterMax},
+	} {
+		if got := retryAfter(tc.h, now); got != tc.want {
+			t.Errorf("%s: retryAfter(%q) = %v, want %v", tc.name, tc.h, got, tc.want)
+		}
+	}
+
+	// no usable value: the first backoff step, backoffBase/2 .. backoffBase
+	for _, h := range []string{"", "soon", "-5", now.Add(-time.Minute).Format(http.TimeFormat)} {
+		if got := retryAfter(h, now); got < backoffBase/2 || got > backoffBase {
+			t.Errorf("retryAfter(%q) = %v, want %v..%v", h, got, backoffBase/2, backoffBase)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for n := 1; n <= 70; n++ {
+		d := backoffBase << (n - 1)
+		if n > 6 || d > backoffMax {
+			d = backoffMax
+		}
+		if got := backoff(n); got < d/2 || got > d {
+			t.Errorf("backoff(%d) = %v, want %v..%v", n, got, d/2, d)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package tiles
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// NiceScale подбирает «круглую» длину масштабной линейки (1, 2, 5 × 10^n метров),
+// не длиннее maxPx пикселей. Возвращает длину в метрах и в пикселях.
+func NiceScale(metersPerPx float64, maxPx int) (meters float64, px int) {
+	if metersPerPx <= 0 || maxPx <= 0 {
+		return 0, 0
+	}
+	limit := metersPerPx * float64(maxPx)
+	p := math.Pow(10, math.Floor(math.Log10(limit)))
+	for _, m := range []float64{5, 2, 1} {
+		if m*p <= limit {
+			meters = m * p
+			break
+		}
+	}
+	return meters, int(math.Round(meters / metersPerPx))
+}
+
+// niceFeet — то же для имперских единиц: футы до мили, дальше мили.
+func niceFeet(metersPerPx float64, maxPx int) (label string, px int) {
+	const ft, mi = 0.3048, 1609.344
+	limit := metersPerPx * float64(maxPx)
+	unit, name := ft, "ft"
+	if limit >= mi {
+		unit, name = mi, "mi"
+	}
+	n := limit / unit
+	p := math.Pow(10, math.Floor(math.Log10(n)))
+	v := p
+	for _, m := range []float64{5, 2, 1} {
+		if m*p <= n {
+			v = m * p
+			break
+		}
+	}
+	return fmt.Sprintf("%g %s", v, name), int(math.Round(v * unit / metersPerPx))
+}
+
+// DrawScaleBar рисует масштабную линейку в левом нижнем углу, bottom — отступ снизу.
+func DrawScaleBar(img *image.RGBA, metersPerPx float64, imperial bool, bottom int, st TextStyle) {
+	b := img.Bounds()
+	maxPx := b.Dx() / 4
+
+	var label string
+	var px int
+	if imperial {
+		label, px = niceFeet(metersPerPx, maxPx)
+	} else {
+		var m float64
+		m, px = NiceScale(metersPerPx, maxPx)
+		label = fmt.Sprintf("%g m", m)
+		if m >= 1000 {
+			label = fmt.Sprintf("%g km", m/1000)
+		}
+	}
+	if px < 2 {
+		return
+	}
+
+	tw, th := st.BoxSize(label)
+	pad := 4
+	w := max(px, tw) + 2*pad
+	h := th + 8 + pad
+	x := b.Min.X + 6
+	y := b.Max.Y - bottom - 6 - h
+	if st.Panel != nil {
+		draw.Draw(img, image.Rect(x, y, x+w, y+h), image.NewUniform(st.Panel), image.Point{}, draw.Over)
+	}
+	txt := st
+	txt.Panel = nil
+	DrawText(img, x+pad, y, label, txt)
+
+	// линейка: основание с засечками по краям, чёрная подложка под белой линией
+	bx, by := x+pad, y+th+4
+	for _, c := range []struct {
+		col color.Color
+		g   int
+	}{{color.RGBA{0, 0, 0, 220}, 1}, {color.RGBA{255, 255, 255, 255}, 0}} {
+		fillRect(img, image.Rect(bx-c.g, by-c.g, bx+px+c.g, by+2+c.g), c.col)
+		fillRect(img, image.Rect(bx-c.g, by-5-c.g, bx+2+c.g, by+2+c.g), c.col)
+		fillRect(img, image.Rect(bx+px-2-c.g, by-5-c.g, bx+px+c.g, by+2+c.g), c.col)
+	}
+}
+
+// DrawNorthArrow рисует стрелку севера с буквой «N» в правом верхнем углу.
+func DrawNorthArrow(img *image.RGBA, st TextStyle) {
+	b := img.Bounds()
+	size := 28
+	tw, th := st.BoxSize("N")
+	cx := b.Max.X - 6 - max(size, tw)/2 - 2
+	top := b.Min.Y + 6
+
+	txt := st
+	DrawText(img, cx-tw/2, top, "N", txt)
+
+	// две половинки наконечника: тёмная и светлая
+	ay := top + th + 2
+	tip := [2]float64{float64(cx), float64(ay)}
+	left := [2]float64{float64(cx) - float64(size)/3, float64(ay + size)}
+	right := [2]float64{float64(cx) + float64(size)/3, float64(ay + size)}
+	notch := [2]float64{float64(cx), float64(ay) + float64(size)*0.7}
+	FillTriangle(img, tip, left, notch, color.RGBA{255, 255, 255, 240})
+	FillTriangle(img, tip, notch, right, color.RGBA{30, 30, 30, 240})
+}
+
+func fillRect(img *image.RGBA, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Over)
+}
+
+// FillTriangle fills triangle a, b, c (pixel coordinates, either winding)
+// with col composited over img; a pixel is inside when its center is.
+func FillTriangle(img *image.RGBA, a, b, c [2]float64, col color.Color) {
+	box := image.Rect(
+		int(math.Floor(math.Min(a[0], math.Min(b[0], c[0])))),
+		int(math.Floor(math.Min(a[1], math.Min(b[1], c[1])))),
+		int(math.Ceil(math.Max(a[0], math.Max(b[0], c[0]))))+1,
+		int(math.Ceil(math.Max(a[1], math.Max(b[1], c[1]))))+1,
+	).Intersect(img.Bounds())
+	edge := func(p, q [2]float64, x, y float64) float64 {
+		return (q[0]-p[0])*(y-p[1]) - (q[1]-p[1])*(x-p[0])
+	}
+	u := image.NewUniform(col)
+	for y := box.Min.Y; y < box.Max.Y; y++ {
+		for x := box.Min.X; x < box.Max.X; x++ {
+			fx, fy := float64(x)+0.5, float64(y)+0.5
+			e0, e1, e2 := edge(a, b, fx, fy), edge(b, c, fx, fy), edge(c, a, fx, fy)
+			if (e0 >= 0 && e1 >= 0 && e2 >= 0) || (e0 <= 0 && e1 <= 0 && e2 <= 0) {
+				draw.Draw(img, image.Rect(x, y, x+1, y+1), u, image.Point{}, draw.Over)
+			}
+		}
+	}
+}
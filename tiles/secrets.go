@@ -0,0 +1,192 @@
+package tiles
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Secrets holds KEY=VALUE pairs from a secrets file. Environment variables
+// take precedence over the file.
+type Secrets map[string]string
+
+// LoadSecrets reads a dotenv-style file: KEY=VALUE per line, '#' comments,
+// optional "export " prefix and surrounding quotes. An empty path yields no secrets.
+func LoadSecrets(path string) (Secrets, error) {
+	s := Secrets{}
+	if path == "" {
+		return s, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		k, v, ok := strings.Cut(line, "=")
+		k = strings.TrimSpace(k)
+		if !ok || !varName.MatchString(k) {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, n)
+		}
+		v = strings.TrimSpace(v)
+		if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+			v = v[1 : len(v)-1]
+		}
+		s[k] = v
+	}
+	return s, sc.Err()
+}
+
+// Lookup returns the value of name from the environment, then from the file.
+func (s Secrets) Lookup(name string) (string, bool) {
+	if v, ok := os.LookupEnv(name); ok && v != "" {
+		return v, true
+	}
+	v, ok := s[name]
+	return v, ok && v != ""
+}
+
+var (
+	varName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	varRef  = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+)
+
+// ExpandVars replaces ${NAME} placeholders. Every substituted value is
+// registered for redaction. All missing names are reported in one error.
+func (s Secrets) ExpandVars(str string) (string, error) {
+	var missing []string
+	out := varRef.ReplaceAllStringFunc(str, func(m string) string {
+		name := varRef.FindStringSubmatch(m)[1]
+		v, ok := s.Lookup(name)
+		if !ok {
+			missing = append(missing, name)
+			return m
+		}
+		RegisterSecret(v)
+		return v
+	})
+	if len(missing) > 0 {
+		return "", &MissingVarsError{Names: missing}
+	}
+	return out, nil
+}
+
+// MissingVarsError lists placeholders that have no value.
+type MissingVarsError struct {
+	Names []string
+}
+
+func (e *MissingVarsError) Error() string {
+	return fmt.Sprintf("not set: %s (export it or put it into the -secrets file)", strings.Join(e.Names, ", "))
+}
+
+// Expand resolves ${NAME} placeholders in the URL template and headers.
+func (p Preset) Expand(s Secrets) (Preset, error) {
+	var missing []string
+	collect := func(err error) {
+		var me *MissingVarsError
+		if errors.As(err, &me) {
+			missing = append(missing, me.Names...)
+		}
+	}
+
+	u, err := s.ExpandVars(p.URLTmpl)
+	collect(err)
+	p.URLTmpl = u
+
+	if len(p.Headers) > 0 {
+		h := make(map[string]string, len(p.Headers))
+		for k, v := range p.Headers {
+			ev, err := s.ExpandVars(v)
+			collect(err)
+			h[k] = ev
+		}
+		p.Headers = h
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		missing = dedup(missing)
+		return p, fmt.Errorf("preset %s: %w", p.Name, &MissingVarsError{Names: missing})
+	}
+	return p, nil
+}
+
+func dedup(xs []string) []string {
+	out := xs[:0]
+	for i, x := range xs {
+		if i == 0 || x != xs[i-1] {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+// RegisterSecret marks a value to be masked by Redact.
+func RegisterSecret(v string) {
+	if len(v) < 4 {
+		return // too short to mask without mangling unrelated text
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, s := range secrets {
+		if s == v {
+			return
+		}
+	}
+	secrets = append(secrets, v)
+}
+
+// Redact masks registered secrets (also in their URL-escaped form) in s.
+func Redact(s string) string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	for _, v := range secrets {
+		s = strings.ReplaceAll(s, v, "***")
+		if q := url.QueryEscape(v); q != v {
+			s = strings.ReplaceAll(s, q, "***")
+		}
+	}
+	return s
+}
+
+// RedactErr masks secrets in the message of err, including the URL of a
+// *url.Error; the error stays unwrappable.
+func RedactErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		ue.URL = Redact(ue.URL)
+	}
+	if msg := Redact(err.Error()); msg != err.Error() {
+		return &redactedError{msg: msg, err: err}
+	}
+	return err
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
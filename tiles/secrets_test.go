@@ -0,0 +1,145 @@
+package tiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.env")
+	content := `# tile provider keys
+
+MAPTILER_KEY=plain
+export STADIA_KEY = "double quoted"
+  SINGLE='single quoted'
+EMPTY=
+HASH=abc#def
+MIXED="not closed'
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadSecrets(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Secrets{
+		"MAPTILER_KEY": "plain",
+		"STADIA_KEY":   "double quoted",
+		"SINGLE":       "single quoted",
+		"EMPTY":        "",
+		"HASH":         "abc#def",
+		"MIXED":        `"not closed'`,
+	}
+	if len(s) != len(want) {
+		t.Errorf("got %d keys %v, want %d", len(s), s, len(want))
+	}
+	for k, v := range want {
+		if s[k] != v {
+			t.Errorf("%s = %q, want %q", k, s[k], v)
+		}
+	}
+}
+
+func TestLoadSecretsErrors(t *testing.T) {
+	if s, err := LoadSecrets(""); err != nil || len(s) != 0 {
+		t.Errorf("empty path: %v, %v", s, err)
+	}
+	if _, err := LoadSecrets(filepath.Join(t.TempDir(), "missing.env")); err == nil {
+		t.Error("missing file: no error")
+	}
+	for _, line := range []string{"no equals sign", "1KEY=v", "BAD-NAME=v"} {
+		path := filepath.Join(t.TempDir(), "bad.env")
+		if err := os.WriteFile(path, []byte("OK=1\n"+line+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadSecrets(path)
+		if err == nil || !strings.Contains(err.Error(), ":2:") {
+			t.Errorf("%q: error %v, want one on line 2", line, err)
+		}
+	}
+}
+
+func TestExpandVars(t *testing.T) {
+	t.Setenv("GPX2GIF_TEST_ENV", "from-env-1234")
+	t.Setenv("GPX2GIF_TEST_EMPTY", "")
+	s := Secrets{
+		"GPX2GIF_TEST_ENV":   "from-file-1234",
+		"GPX2GIF_TEST_FILE":  "file-only-5678",
+		"GPX2GIF_TEST_EMPTY": "file-fallback-90",
+	}
+	got, err := s.ExpandVars("https://t/{z}/{x}/{y}.png?a=${GPX2GIF_TEST_ENV}&b=${GPX2GIF_TEST_FILE}&c=${GPX2GIF_TEST_EMPTY}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the environment beats the file; an empty variable falls back to the file
+	if want := "https://t/{z}/{x}/{y}.png?a=from-env-1234&b=file-only-5678&c=file-fallback-90"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if r := Redact(got); strings.Contains(r, "from-env-1234") || strings.Contains(r, "file-only-5678") {
+		t.Errorf("expanded values not registered for redaction: %q", r)
+	}
+}
+
+func TestExpandVarsMissing(t *testing.T) {
+	s := Secrets{"GPX2GIF_TEST_SET": "value-1234"}
+	_, err := s.ExpandVars("${GPX2GIF_TEST_UNSET_A}/${GPX2GIF_TEST_SET}/${GPX2GIF_TEST_UNSET_B}")
+	var me *MissingVarsError
+	if !errors.As(err, &me) {
+		t.Fatalf("error %v, want *MissingVarsError", err)
+	}
+	if len(me.Names) != 2 || me.Names[0] != "GPX2GIF_TEST_UNSET_A" || me.Names[1] != "GPX2GIF_TEST_UNSET_B" {
+		t.Errorf("missing %v", me.Names)
+	}
+
+	// a preset with an unset key fails on Expand, before a source is built
+	p := Preset{
+		Name:    "keyed",
+		URLTmpl: "https://t/{z}/{x}/{y}.png?key=${GPX2GIF_TEST_UNSET_B}",
+		Headers: map[string]string{"Authorization": "Bearer ${GPX2GIF_TEST_UNSET_A}", "X-Other": "${GPX2GIF_TEST_UNSET_B}"},
+	}
+	if _, err := p.Expand(Secrets{}); err == nil || !strings.Contains(err.Error(), "GPX2GIF_TEST_UNSET_A, GPX2GIF_TEST_UNSET_B") {
+		t.Errorf("Expand: %v, want both names once, sorted", err)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	RegisterSecret("s3cr3t/key+1")
+	RegisterSecret("abc") // too short to mask
+	for _, tc := range []struct{ in, want string }{
+		{"https://t/1/2/3.png?key=s3cr3t/key+1", "https://t/1/2/3.png?key=***"},
+		{"https://t/1/2/3.png?key=" + url.QueryEscape("s3cr3t/key+1"), "https://t/1/2/3.png?key=***"},
+		{"abc stays", "abc stays"},
+		{"nothing to hide", "nothing to hide"},
+	} {
+		if got := Redact(tc.in); got != tc.want {
+			t.Errorf("Redact(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestRedactErr(t *testing.T) {
+	RegisterSecret("err-secret-42")
+	if RedactErr(nil) != nil {
+		t.Error("RedactErr(nil) != nil")
+	}
+	ue := &url.Error{Op: "Get", URL: "https://t/1.png?key=err-secret-42", Err: context.Canceled}
+	err := RedactErr(ue)
+	if strings.Contains(err.Error(), "err-secret-42") {
+		t.Errorf("url.Error not masked: %v", err)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("%v no longer unwraps to context.Canceled", err)
+	}
+
+	wrapped := RedactErr(fmt.Errorf("server said: bad key err-secret-42: %w", ErrAccessDenied))
+	if strings.Contains(wrapped.Error(), "err-secret-42") || !errors.Is(wrapped, ErrAccessDenied) {
+		t.Errorf("wrapped error %v: not masked or not unwrappable", wrapped)
+	}
+}
@@ -0,0 +1,62 @@
+package tiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Source yields encoded tile images (PNG/JPEG) addressed in the XYZ scheme.
+type Source interface {
+	Tile(ctx context.Context, z, x, y int) ([]byte, error)
+}
+
+// ErrNoTile is returned by a Source that has no tile at the requested address.
+var ErrNoTile = errors.New("tile not found")
+
+// HTTPSource serves tiles from a URL template through a caching, rate-limited Fetcher.
+type HTTPSource struct {
+	F      *Fetcher
+	Preset Preset
+}
+
+func (s *HTTPSource) Tile(ctx context.Context, z, x, y int) ([]byte, error) {
+	u, hdrs, err := s.F.URLFor(s.Preset, z, x, y)
+	if err != nil {
+		return nil, err
+	}
+	data, _, err := s.F.GetTile(ctx, u, hdrs)
+	if err != nil {
+		return nil, fmt.Errorf("get tile %s: %w", Redact(u), err)
+	}
+	return data, nil
+}
+
+func (s *HTTPSource) workers() int { return s.F.Workers }
+
+func (s *HTTPSource) cachedTile(_ context.Context, z, x, y int) ([]byte, error) {
+	u, _, err := s.F.URLFor(s.Preset, z, x, y)
+	if err != nil {
+		return nil, err
+	}
+	return s.F.cached(u)
+}
+
+// cachedTile looks a tile up without network access where src allows it:
+// HTTP sources answer from the Fetcher cache, local sources as usual.
+func cachedTile(ctx context.Context, src Source, z, x, y int) ([]byte, error) {
+	if c, ok := src.(interface {
+		cachedTile(ctx context.Context, z, x, y int) ([]byte, error)
+	}); ok {
+		return c.cachedTile(ctx, z, x, y)
+	}
+	return src.Tile(ctx, z, x, y)
+}
+
+// workersFor is the pool size for src: sources may declare their own limit.
+func workersFor(src Source) int {
+	if w, ok := src.(interface{ workers() int }); ok && w.workers() > 0 {
+		return w.workers()
+	}
+	return DefaultWorkers
+}